@@ -0,0 +1,137 @@
+// xmlrpc-fmt reformats XML-RPC documents and compares them.
+//
+// Usage:
+//
+//	xmlrpc-fmt [-c] [-compact] [-indent str] [-encoding charset] [file ...]
+//	xmlrpc-fmt -d file1 file2
+//
+// Each file (or the standard input if no files are named) must hold a
+// single <methodCall> or <methodResponse>, which is written to the
+// standard output indented by two spaces.  The -compact flag removes all
+// whitespace between tags and -c writes the compact canonical form, where
+// equal documents produce identical output.  The -encoding flag writes
+// the output in another character set ("ISO-8859-1", "windows-1252" or
+// "US-ASCII") and names it in the XML declaration.
+//
+// The -d flag compares two documents, printing the path to the first
+// differing value and exiting with status 1 if they aren't equal.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+)
+
+// parse the document in the named file
+func readDocument(path string) (*xmlrpc.Document, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	doc, err := xmlrpc.ParseDocument(fd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return doc, nil
+}
+
+// reformat a single document from 'in' to 'out'
+func format(out io.Writer, in io.Reader, canonical bool, indent string,
+	encoding string) error {
+	doc, err := xmlrpc.ParseDocument(in)
+	if err != nil {
+		return err
+	}
+
+	if canonical {
+		doc = doc.Canonical()
+	}
+
+	e := xmlrpc.NewEncoder(out)
+	e.SetIndent(indent)
+	if err = e.SetEncoding(encoding); err != nil {
+		return err
+	}
+	return e.Encode(doc)
+}
+
+// compare two documents, returning the exit status
+func diff(path1, path2 string) int {
+	doc1, err := readDocument(path1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "xmlrpc-fmt: %v\n", err)
+		return 2
+	}
+
+	doc2, err := readDocument(path2)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "xmlrpc-fmt: %v\n", err)
+		return 2
+	}
+
+	if d := xmlrpc.Diff(doc1, doc2); d != "" {
+		fmt.Println(d)
+		return 1
+	}
+
+	return 0
+}
+
+func main() {
+	canonical := flag.Bool("c", false, "write the compact canonical form")
+	compact := flag.Bool("compact", false,
+		"remove whitespace between tags")
+	indent := flag.String("indent", "  ", "indentation for each level")
+	encoding := flag.String("encoding", "", "output character set")
+	diffMode := flag.Bool("d", false, "compare two documents")
+	flag.Parse()
+
+	if *diffMode {
+		if flag.NArg() != 2 {
+			fmt.Fprintln(os.Stderr, "xmlrpc-fmt: -d needs two files")
+			os.Exit(2)
+		}
+
+		os.Exit(diff(flag.Arg(0), flag.Arg(1)))
+	}
+
+	if *canonical || *compact {
+		*indent = ""
+	}
+
+	if flag.NArg() == 0 {
+		err := format(os.Stdout, os.Stdin, *canonical, *indent,
+			*encoding)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc-fmt: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		fd, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc-fmt: %v\n", err)
+			status = 1
+			continue
+		}
+
+		err = format(os.Stdout, fd, *canonical, *indent, *encoding)
+		fd.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc-fmt: %s: %v\n", path, err)
+			status = 1
+		}
+	}
+
+	os.Exit(status)
+}
@@ -0,0 +1,78 @@
+// xmlrpc2json converts XML-RPC documents to JSON and back, keeping every
+// value's XML-RPC type (see the xmlrpc package's Document type for the
+// JSON form).
+//
+// Usage:
+//
+//	xmlrpc2json [-r] [-indent] [file ...]
+//
+// Each file (or the standard input if no files are named) must hold a
+// single <methodCall> or <methodResponse>.  The -r flag converts JSON
+// back to XML-RPC.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+)
+
+// convert a single document from 'in' to 'out'
+func convert(out io.Writer, in io.Reader, reverse bool, indent bool) error {
+	if reverse {
+		return xmlrpc.JSONToXML(out, in)
+	} else if !indent {
+		return xmlrpc.XMLToJSON(out, in)
+	}
+
+	var buf bytes.Buffer
+	if err := xmlrpc.XMLToJSON(&buf, in); err != nil {
+		return err
+	}
+
+	var ibuf bytes.Buffer
+	if err := json.Indent(&ibuf, buf.Bytes(), "", "  "); err != nil {
+		return err
+	}
+
+	_, err := ibuf.WriteTo(out)
+	return err
+}
+
+func main() {
+	reverse := flag.Bool("r", false, "convert JSON to XML-RPC")
+	indent := flag.Bool("indent", false, "indent the JSON output")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if err := convert(os.Stdout, os.Stdin, *reverse, *indent); err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc2json: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	status := 0
+	for _, path := range flag.Args() {
+		fd, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc2json: %v\n", err)
+			status = 1
+			continue
+		}
+
+		err = convert(os.Stdout, fd, *reverse, *indent)
+		fd.Close()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc2json: %s: %v\n", path, err)
+			status = 1
+		}
+	}
+
+	os.Exit(status)
+}
@@ -0,0 +1,249 @@
+package validator1
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"time"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+)
+
+// characters used to build the countTheEntities string
+const entityChars = "<>&'\"abc xyz"
+
+// call a validator1 method and decode the result into 'reply'
+func call(c *xmlrpc.Client, methodName string, reply interface{},
+	args ...interface{}) error {
+	val, err, fault := c.RPCCall(namespace+"."+methodName, args...)
+	if err != nil {
+		return fmt.Errorf("%s failed: %v", methodName, err)
+	} else if fault != nil {
+		return fmt.Errorf("%s returned fault: %v", methodName, fault)
+	}
+
+	if err = xmlrpc.Decode(val, reply); err != nil {
+		return fmt.Errorf("%s returned %v: %v", methodName, val, err)
+	}
+
+	return nil
+}
+
+// return a set of stooges with small random values
+func randomStooges(rnd *rand.Rand) Stooges {
+	return Stooges{Moe: rnd.Intn(200) - 100, Larry: rnd.Intn(200) - 100,
+		Curly: rnd.Intn(200) - 100}
+}
+
+func checkArrayOfStructs(c *xmlrpc.Client, rnd *rand.Rand) error {
+	list := make([]Stooges, 1+rnd.Intn(10))
+	expected := 0
+	for i := range list {
+		list[i] = randomStooges(rnd)
+		expected += list[i].Curly
+	}
+
+	var sum int
+	if err := call(c, "arrayOfStructsTest", &sum, list); err != nil {
+		return err
+	} else if sum != expected {
+		return fmt.Errorf("arrayOfStructsTest returned %d, expected %d",
+			sum, expected)
+	}
+
+	return nil
+}
+
+func checkCountTheEntities(c *xmlrpc.Client, rnd *rand.Rand) error {
+	buf := make([]byte, 20+rnd.Intn(50))
+	for i := range buf {
+		buf[i] = entityChars[rnd.Intn(len(entityChars))]
+	}
+
+	expected := (&Validator{}).CountTheEntities(string(buf))
+
+	var counts EntityCounts
+	if err := call(c, "countTheEntities", &counts,
+		string(buf)); err != nil {
+		return err
+	} else if counts != expected {
+		return fmt.Errorf("countTheEntities(%q) returned %+v, expected %+v",
+			buf, counts, expected)
+	}
+
+	return nil
+}
+
+func checkEasyStruct(c *xmlrpc.Client, rnd *rand.Rand) error {
+	s := randomStooges(rnd)
+	expected := s.Moe + s.Larry + s.Curly
+
+	var sum int
+	if err := call(c, "easyStructTest", &sum, s); err != nil {
+		return err
+	} else if sum != expected {
+		return fmt.Errorf("easyStructTest returned %d, expected %d", sum,
+			expected)
+	}
+
+	return nil
+}
+
+func checkEchoStruct(c *xmlrpc.Client, rnd *rand.Rand) error {
+	s := map[string]interface{}{
+		"substruct0": map[string]interface{}{
+			"moe": rnd.Intn(100), "larry": rnd.Intn(100),
+		},
+		"name":  "A & B <c>",
+		"count": rnd.Intn(1000),
+		"list":  []interface{}{"x", rnd.Intn(10), true},
+	}
+
+	var echo map[string]interface{}
+	if err := call(c, "echoStructTest", &echo, s); err != nil {
+		return err
+	} else if !reflect.DeepEqual(echo, s) {
+		return fmt.Errorf("echoStructTest returned %v, expected %v", echo,
+			s)
+	}
+
+	return nil
+}
+
+func checkManyTypes(c *xmlrpc.Client, rnd *rand.Rand) error {
+	num := rnd.Intn(10000) - 5000
+	flag := rnd.Intn(2) == 1
+	str := "Some <escaped> & 'quoted' \"text\""
+	dbl := float64(rnd.Intn(1000)) / 8
+	when := time.Date(1990+rnd.Intn(30), time.Month(1+rnd.Intn(12)),
+		1+rnd.Intn(28), rnd.Intn(24), rnd.Intn(60), rnd.Intn(60), 0,
+		time.UTC)
+	data := make([]byte, 1+rnd.Intn(40))
+	rnd.Read(data)
+
+	var reply []interface{}
+	if err := call(c, "manyTypesTest", &reply, num, flag, str, dbl, when,
+		data); err != nil {
+		return err
+	} else if len(reply) != 6 {
+		return fmt.Errorf("manyTypesTest returned %d values, expected 6",
+			len(reply))
+	}
+
+	if reply[0] != num || reply[1] != flag || reply[2] != str ||
+		reply[3] != dbl {
+		return fmt.Errorf("manyTypesTest returned %v, expected"+
+			" [%v %v %q %v ...]", reply, num, flag, str, dbl)
+	}
+
+	if tm, ok := reply[4].(time.Time); !ok || !tm.Equal(when) {
+		return fmt.Errorf("manyTypesTest returned date %v, expected %v",
+			reply[4], when)
+	}
+
+	if b, ok := reply[5].([]byte); !ok || !bytes.Equal(b, data) {
+		return fmt.Errorf("manyTypesTest returned base64 %v, expected %v",
+			reply[5], data)
+	}
+
+	return nil
+}
+
+func checkModerateSizeArray(c *xmlrpc.Client, rnd *rand.Rand) error {
+	list := make([]string, 100+rnd.Intn(101))
+	for i := range list {
+		list[i] = fmt.Sprintf("item%d-%d", i, rnd.Intn(1000))
+	}
+	expected := list[0] + list[len(list)-1]
+
+	var str string
+	if err := call(c, "moderateSizeArrayCheck", &str, list); err != nil {
+		return err
+	} else if str != expected {
+		return fmt.Errorf("moderateSizeArrayCheck returned %q, expected %q",
+			str, expected)
+	}
+
+	return nil
+}
+
+func checkNestedStruct(c *xmlrpc.Client, rnd *rand.Rand) error {
+	var expected int
+
+	calendar := make(map[string]map[string]map[string]Stooges)
+	for year := 1999; year <= 2001; year++ {
+		ystr := fmt.Sprintf("%04d", year)
+		calendar[ystr] = make(map[string]map[string]Stooges)
+		for month := 1; month <= 12; month++ {
+			mstr := fmt.Sprintf("%02d", month)
+			calendar[ystr][mstr] = make(map[string]Stooges)
+			for day := 1; day <= 28; day++ {
+				s := randomStooges(rnd)
+				calendar[ystr][mstr][fmt.Sprintf("%02d", day)] = s
+				if year == 2000 && month == 4 && day == 1 {
+					expected = s.Moe + s.Larry + s.Curly
+				}
+			}
+		}
+	}
+
+	var sum int
+	if err := call(c, "nestedStructTest", &sum, calendar); err != nil {
+		return err
+	} else if sum != expected {
+		return fmt.Errorf("nestedStructTest returned %d, expected %d", sum,
+			expected)
+	}
+
+	return nil
+}
+
+func checkSimpleStructReturn(c *xmlrpc.Client, rnd *rand.Rand) error {
+	n := 1 + rnd.Intn(1000)
+	expected := Multiples{Times10: n * 10, Times100: n * 100,
+		Times1000: n * 1000}
+
+	var reply Multiples
+	if err := call(c, "simpleStructReturnTest", &reply, n); err != nil {
+		return err
+	} else if reply != expected {
+		return fmt.Errorf("simpleStructReturnTest(%d) returned %+v,"+
+			" expected %+v", n, reply, expected)
+	}
+
+	return nil
+}
+
+// the validator1 checks, in the order they're run
+var checks = []func(*xmlrpc.Client, *rand.Rand) error{
+	checkArrayOfStructs,
+	checkCountTheEntities,
+	checkEasyStruct,
+	checkEchoStruct,
+	checkManyTypes,
+	checkModerateSizeArray,
+	checkNestedStruct,
+	checkSimpleStructReturn,
+}
+
+// run every validator1 test against the server used by the client,
+// returning an error describing all the tests which failed
+func Check(c *xmlrpc.Client) error {
+	return CheckSeed(c, 1)
+}
+
+// run every validator1 test using inputs generated from 'seed'
+func CheckSeed(c *xmlrpc.Client, seed int64) error {
+	rnd := rand.New(rand.NewSource(seed))
+
+	var errs []error
+	for _, check := range checks {
+		if err := check(c, rnd); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+// Regenerate the Apache XML-RPC 3 payloads in this directory, with
+// xmlrpc-client 3.1.3 and its dependencies on the class path:
+//
+//	cd validator1/testdata/apache && javac Generate.java && java Generate
+//
+// Requests are written without extensions, as most clients send them;
+// response-extensions.xml shows the <ex:i8> and <ex:nil/> extension types.
+
+import java.io.FileOutputStream;
+import java.io.OutputStream;
+import java.util.Arrays;
+import java.util.Calendar;
+import java.util.GregorianCalendar;
+import java.util.LinkedHashMap;
+import java.util.Map;
+import java.util.TimeZone;
+
+import org.apache.xmlrpc.XmlRpcRequest;
+import org.apache.xmlrpc.client.XmlRpcClient;
+import org.apache.xmlrpc.client.XmlRpcClientConfigImpl;
+import org.apache.xmlrpc.client.XmlRpcClientRequestImpl;
+import org.apache.xmlrpc.serializer.XmlRpcWriter;
+import org.xml.sax.ContentHandler;
+
+public class Generate {
+	private static XmlRpcClient client = new XmlRpcClient();
+
+	public static void main(String[] args) throws Exception {
+		XmlRpcClientConfigImpl config = newConfig(false);
+
+		Calendar cal = new GregorianCalendar(TimeZone.getTimeZone("UTC"));
+		cal.clear();
+		cal.set(2000, Calendar.APRIL, 1, 12, 30, 0);
+
+		writeRequest(config, "arrayOfStructsTest", new Object[] {
+			new Object[] {stooges(1, 2, 3), stooges(4, 5, 6)}});
+		writeRequest(config, "countTheEntities",
+			new Object[] {"a<b>&c'd\"e<<"});
+		writeRequest(config, "easyStructTest",
+			new Object[] {stooges(5, 7, -3)});
+		writeRequest(config, "manyTypesTest", new Object[] {
+			42, true, "hello & <world>", 3.5, cal.getTime(),
+			"hello".getBytes("UTF-8")});
+		writeRequest(config, "simpleStructReturnTest", new Object[] {7});
+
+		try (OutputStream out = new FileOutputStream("response-fault.xml")) {
+			newWriter(config, out).write(config, 4, "Too many <params>");
+		}
+
+		XmlRpcClientConfigImpl extConfig = newConfig(true);
+		try (OutputStream out =
+			new FileOutputStream("response-extensions.xml")) {
+			newWriter(extConfig, out).write(extConfig, new Object[] {
+				9007199254740993L, null, -7, "plain"});
+		}
+	}
+
+	private static XmlRpcClientConfigImpl newConfig(boolean extensions) {
+		XmlRpcClientConfigImpl config = new XmlRpcClientConfigImpl();
+		config.setEnabledForExtensions(extensions);
+		config.setTimeZone(TimeZone.getTimeZone("UTC"));
+		config.setEncoding("UTF-8");
+		return config;
+	}
+
+	// keep the members in the order used by the other implementations
+	private static Map<String, Object> stooges(int moe, int larry,
+		int curly) {
+		Map<String, Object> m = new LinkedHashMap<String, Object>();
+		m.put("moe", moe);
+		m.put("larry", larry);
+		m.put("curly", curly);
+		return m;
+	}
+
+	private static XmlRpcWriter newWriter(XmlRpcClientConfigImpl config,
+		OutputStream out) throws Exception {
+		ContentHandler h =
+			client.getXmlWriterFactory().getXmlWriter(config, out);
+		return new XmlRpcWriter(config, h, client.getTypeFactory());
+	}
+
+	private static void writeRequest(XmlRpcClientConfigImpl config,
+		String name, Object[] params) throws Exception {
+		XmlRpcRequest req = new XmlRpcClientRequestImpl(config,
+			"validator1." + name, Arrays.asList(params));
+		try (OutputStream out = new FileOutputStream(name + ".xml")) {
+			newWriter(config, out).write(req);
+		}
+	}
+}
@@ -0,0 +1,42 @@
+<?php
+//
+// Regenerate the PHP xmlrpc-epi payloads in this directory (needs the
+// xmlrpc extension, which was bundled with PHP up to 7.4):
+//
+//	cd validator1/testdata/php && php generate.php
+
+$datetime = "20000401T12:30:00";
+xmlrpc_set_type($datetime, "datetime");
+
+$base64 = "hello";
+xmlrpc_set_type($base64, "base64");
+
+$requests = array(
+    "countTheEntities" => array("a<b>&c'd\"e<<"),
+    "easyStructTest" => array(array("moe" => 5, "larry" => 7,
+                                    "curly" => -3)),
+    "manyTypesTest" => array(42, true, "hello & <world>", 3.5, $datetime,
+                             $base64),
+    "simpleStructReturnTest" => array(7),
+);
+
+$responses = array(
+    "response-entities" => array("ctLeftAngleBrackets" => 3,
+                                 "ctRightAngleBrackets" => 1,
+                                 "ctAmpersands" => 1, "ctApostrophes" => 1,
+                                 "ctQuotes" => 1),
+    // a struct with faultCode and faultString is encoded as a <fault>
+    "response-fault" => array("faultCode" => 4,
+                              "faultString" => "Too many <params>"),
+);
+
+foreach ($requests as $name => $params) {
+    file_put_contents($name . ".xml",
+        xmlrpc_encode_request("validator1." . $name, $params));
+}
+
+// a null method name makes a methodResponse
+foreach ($responses as $name => $result) {
+    file_put_contents($name . ".xml",
+        xmlrpc_encode_request(null, $result));
+}
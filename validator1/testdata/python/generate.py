@@ -0,0 +1,70 @@
+#!/usr/bin/env python3
+#
+# Regenerate the Python xmlrpc.client payloads in this directory:
+#
+#	cd validator1/testdata/python && python3 generate.py
+
+import xmlrpc.client
+
+REQUESTS = {
+    "arrayOfStructsTest": ([
+        {"moe": 1, "larry": 2, "curly": 3},
+        {"moe": 4, "larry": 5, "curly": 6},
+    ],),
+    "countTheEntities": ("a<b>&c'd\"e<<",),
+    "easyStructTest": ({"moe": 5, "larry": 7, "curly": -3},),
+    "echoStructTest": ({"substruct0": {"moe": 1, "larry": 2},
+                        "name": "x"},),
+    "manyTypesTest": (42, True, "hello & <world>", 3.5,
+                      xmlrpc.client.DateTime("20000401T12:30:00"),
+                      xmlrpc.client.Binary(b"hello")),
+    "moderateSizeArrayCheck": (["s%d" % i for i in range(100)],),
+    "nestedStructTest": ({
+        "1999": {"04": {"01": {"moe": 100, "larry": 100, "curly": 100}}},
+        "2000": {
+            "03": {"31": {"moe": 9, "larry": 9, "curly": 9}},
+            "04": {"01": {"moe": 1, "larry": 2, "curly": 3},
+                   "02": {"moe": 7, "larry": 7, "curly": 7}},
+        },
+    },),
+    "simpleStructReturnTest": (7,),
+}
+
+RESPONSES = {
+    "response-entities": {"ctLeftAngleBrackets": 3,
+                          "ctRightAngleBrackets": 1, "ctAmpersands": 1,
+                          "ctApostrophes": 1, "ctQuotes": 1},
+    "response-manytypes": [42, True, "hello & <world>", 3.5,
+                           xmlrpc.client.DateTime("20000401T12:30:00"),
+                           xmlrpc.client.Binary(b"hello"), None],
+}
+
+# requests sent by a ServerProxy created with encoding=..., which names the
+# character set in the XML declaration and writes characters outside it
+# as character references
+ENCODED_REQUESTS = {
+    "echoStructTest": ({
+        "name": "caf\u00e9 \u00a311 \u201cd\u00e9j\u00e0\u201d \u2013 \u20ac5",
+        "substruct0": {"moe": 1, "larry": 2},
+    },),
+}
+
+for name, params in REQUESTS.items():
+    with open(name + ".xml", "w") as f:
+        f.write(xmlrpc.client.dumps(params, "validator1." + name))
+
+for name, result in RESPONSES.items():
+    with open(name + ".xml", "w") as f:
+        f.write(xmlrpc.client.dumps((result,), methodresponse=True,
+                                    allow_none=True))
+
+with open("response-fault.xml", "w") as f:
+    f.write(xmlrpc.client.dumps(xmlrpc.client.Fault(4, "Too many <params>"),
+                                methodresponse=True))
+
+for encoding in ("iso-8859-1", "windows-1252"):
+    for name, params in ENCODED_REQUESTS.items():
+        with open("%s.%s.xml" % (name, encoding), "wb") as f:
+            f.write(xmlrpc.client.dumps(params, "validator1." + name,
+                                        encoding=encoding)
+                    .encode(encoding, "xmlcharrefreplace"))
@@ -0,0 +1,120 @@
+/*
+Package validator1 implements the classic Userland XML-RPC "validator1"
+suite, which exercises the parts of XML-RPC that implementations most
+often get wrong: structs, arrays, entity escaping and every scalar type.
+
+Register adds the eight validator1 methods to a Handler, so other
+implementations can be checked against this one:
+
+	h := xmlrpc.NewHandler()
+	validator1.Register(h)
+
+Check does the reverse, calling the methods on any validator1 server and
+verifying the results:
+
+	if err := validator1.Check(client); err != nil {
+		fmt.Fprintf(os.Stderr, "Validation failed: %v\n", err)
+	}
+*/
+package validator1
+
+import (
+	"strings"
+	"time"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+)
+
+// namespace of the validator1 methods
+const namespace = "validator1"
+
+// Stooges is the struct used by several validator1 tests
+type Stooges struct {
+	Moe   int `xmlrpc:"moe"`
+	Larry int `xmlrpc:"larry"`
+	Curly int `xmlrpc:"curly"`
+}
+
+// EntityCounts is the result of countTheEntities
+type EntityCounts struct {
+	LeftAngleBrackets  int `xmlrpc:"ctLeftAngleBrackets"`
+	RightAngleBrackets int `xmlrpc:"ctRightAngleBrackets"`
+	Ampersands         int `xmlrpc:"ctAmpersands"`
+	Apostrophes        int `xmlrpc:"ctApostrophes"`
+	Quotes             int `xmlrpc:"ctQuotes"`
+}
+
+// Multiples is the result of simpleStructReturnTest
+type Multiples struct {
+	Times10   int `xmlrpc:"times10"`
+	Times100  int `xmlrpc:"times100"`
+	Times1000 int `xmlrpc:"times1000"`
+}
+
+// Validator implements the validator1 methods
+type Validator struct{}
+
+// add the validator1 methods (e.g. "validator1.easyStructTest") to the
+// handler
+func Register(h *xmlrpc.Handler) error {
+	return h.RegisterName(namespace, &Validator{}, false)
+}
+
+// return the sum of the "curly" members of the structs
+func (v *Validator) ArrayOfStructsTest(list []Stooges) int {
+	sum := 0
+	for _, s := range list {
+		sum += s.Curly
+	}
+
+	return sum
+}
+
+// count the characters which must be escaped in XML
+func (v *Validator) CountTheEntities(s string) EntityCounts {
+	return EntityCounts{
+		LeftAngleBrackets:  strings.Count(s, "<"),
+		RightAngleBrackets: strings.Count(s, ">"),
+		Ampersands:         strings.Count(s, "&"),
+		Apostrophes:        strings.Count(s, "'"),
+		Quotes:             strings.Count(s, "\""),
+	}
+}
+
+// return the sum of the struct's members
+func (v *Validator) EasyStructTest(s Stooges) int {
+	return s.Moe + s.Larry + s.Curly
+}
+
+// return the struct unchanged
+func (v *Validator) EchoStructTest(
+	s map[string]interface{}) map[string]interface{} {
+	return s
+}
+
+// return the parameters in an array
+func (v *Validator) ManyTypesTest(num int, flag bool, str string,
+	dbl float64, when time.Time, data []byte) []interface{} {
+	return []interface{}{num, flag, str, dbl, when, data}
+}
+
+// return the first and last strings in the array joined together
+func (v *Validator) ModerateSizeArrayCheck(list []string) string {
+	if len(list) == 0 {
+		return ""
+	}
+
+	return list[0] + list[len(list)-1]
+}
+
+// return the sum of the members of the struct for April 1st, 2000
+func (v *Validator) NestedStructTest(
+	calendar map[string]map[string]map[string]Stooges) int {
+	return v.EasyStructTest(calendar["2000"]["04"]["01"])
+}
+
+// return the number multiplied by 10, 100 and 1000
+func (v *Validator) SimpleStructReturnTest(n int) Multiples {
+	return Multiples{Times10: n * 10, Times100: n * 100,
+		Times1000: n * 1000}
+}
@@ -0,0 +1,208 @@
+package validator1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+)
+
+// the client implementations whose payloads are in testdata
+var implementations = []string{"apache", "php", "python"}
+
+// expected results of the corpus requests, keyed by file name without
+// the ".xml" suffix (the method name, followed by the character set for
+// requests which aren't in UTF-8)
+var corpusResults = map[string]interface{}{
+	"arrayOfStructsTest": 9,
+	"countTheEntities": map[string]interface{}{
+		"ctLeftAngleBrackets": 3, "ctRightAngleBrackets": 1,
+		"ctAmpersands": 1, "ctApostrophes": 1, "ctQuotes": 1,
+	},
+	"easyStructTest": 9,
+	"echoStructTest": map[string]interface{}{
+		"substruct0": map[string]interface{}{"moe": 1, "larry": 2},
+		"name":       "x",
+	},
+	"echoStructTest.iso-8859-1": map[string]interface{}{
+		"substruct0": map[string]interface{}{"moe": 1, "larry": 2},
+		"name":       "café £11 “déjà” – €5",
+	},
+	"echoStructTest.windows-1252": map[string]interface{}{
+		"substruct0": map[string]interface{}{"moe": 1, "larry": 2},
+		"name":       "café £11 “déjà” – €5",
+	},
+	"manyTypesTest": []interface{}{42, true, "hello & <world>", 3.5,
+		time.Date(2000, 4, 1, 12, 30, 0, 0, time.UTC), []byte("hello")},
+	"moderateSizeArrayCheck": "s0s99",
+	"nestedStructTest":       6,
+	"simpleStructReturnTest": map[string]interface{}{
+		"times10": 70, "times100": 700, "times1000": 7000,
+	},
+}
+
+// expected values of the corpus responses, keyed by file name
+var corpusResponses = map[string]interface{}{
+	"response-entities.xml": corpusResults["countTheEntities"],
+	"response-extensions.xml": []interface{}{int64(9007199254740993), nil,
+		-7, "plain"},
+	"response-manytypes.xml": []interface{}{42, true, "hello & <world>",
+		3.5, time.Date(2000, 4, 1, 12, 30, 0, 0, time.UTC),
+		[]byte("hello"), nil},
+}
+
+func newValidatorServer(t *testing.T) *httptest.Server {
+	h := xmlrpc.NewHandler()
+	if err := Register(h); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	return httptest.NewServer(h)
+}
+
+func TestCheck(t *testing.T) {
+	srvr := newValidatorServer(t)
+	defer srvr.Close()
+
+	client, err := xmlrpc.NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = Check(client); err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+
+	for seed := int64(2); seed < 10; seed++ {
+		if err = CheckSeed(client, seed); err != nil {
+			t.Errorf("CheckSeed(%d) failed: %v", seed, err)
+		}
+	}
+}
+
+func TestCheckReportsFailures(t *testing.T) {
+	h := xmlrpc.NewHandler()
+	h.RegisterFunc("validator1.easyStructTest",
+		func(s Stooges) int { return 0 }, false)
+
+	srvr := httptest.NewServer(h)
+	defer srvr.Close()
+
+	client, err := xmlrpc.NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = Check(client)
+	if err == nil {
+		t.Fatal("Check succeeded against an incomplete server")
+	}
+
+	for _, name := range []string{"arrayOfStructsTest", "easyStructTest",
+		"simpleStructReturnTest"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Error %q does not mention %s", err, name)
+		}
+	}
+}
+
+// return the corpus files for an implementation
+func corpusFiles(t *testing.T, impl string) []string {
+	files, err := filepath.Glob(filepath.Join("testdata", impl, "*.xml"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(files) == 0 {
+		t.Fatalf("No %s payloads found", impl)
+	}
+
+	return files
+}
+
+func TestCorpusRequests(t *testing.T) {
+	srvr := newValidatorServer(t)
+	defer srvr.Close()
+
+	for _, impl := range implementations {
+		for _, path := range corpusFiles(t, impl) {
+			if strings.HasPrefix(filepath.Base(path), "response-") {
+				continue
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := http.Post(srvr.URL, "text/xml", f)
+			f.Close()
+			if err != nil {
+				t.Fatalf("%s: %v", path, err)
+			}
+
+			_, val, err, fault := xmlrpc.Unmarshal(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Errorf("%s: cannot parse response: %v", path, err)
+				continue
+			} else if fault != nil {
+				t.Errorf("%s: returned fault %v", path, fault)
+				continue
+			}
+
+			name := strings.TrimSuffix(filepath.Base(path), ".xml")
+			if expected, ok := corpusResults[name]; !ok {
+				t.Errorf("%s: no expected result for %s", path, name)
+			} else if !reflect.DeepEqual(val, expected) {
+				t.Errorf("%s: returned %#v, expected %#v", path, val,
+					expected)
+			}
+		}
+	}
+}
+
+func TestCorpusResponses(t *testing.T) {
+	for _, impl := range implementations {
+		for _, path := range corpusFiles(t, impl) {
+			base := filepath.Base(path)
+			if !strings.HasPrefix(base, "response-") {
+				continue
+			}
+
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, val, err, fault := xmlrpc.Unmarshal(f)
+			f.Close()
+			if err != nil {
+				t.Errorf("%s: cannot parse: %v", path, err)
+				continue
+			}
+
+			if base == "response-fault.xml" {
+				if fault == nil || fault.Code != 4 ||
+					fault.Msg != "Too many <params>" {
+					t.Errorf("%s: returned fault %v", path, fault)
+				}
+				continue
+			} else if fault != nil {
+				t.Errorf("%s: returned fault %v", path, fault)
+				continue
+			}
+
+			if expected, ok := corpusResponses[base]; !ok {
+				t.Errorf("%s: no expected value", path)
+			} else if !reflect.DeepEqual(val, expected) {
+				t.Errorf("%s: returned %#v, expected %#v", path, val,
+					expected)
+			}
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package xmlrpc
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+)
+
+// cached error reflect.Type value
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// Bind fills in the function fields of the struct 'stub' points to with
+// functions which call the remote procedures, so remote calls look like
+// local ones:
+//
+//	var obj struct {
+//		GetSize func() (int, error)          `xmlrpc:"obj.getSize"`
+//		SetSize func(size int) error          `xmlrpc:"obj.setSize"`
+//		Find    func(ctx context.Context, pattern string) ([]string, error)
+//	}
+//	err := client.Bind(&obj, xmlrpc.LowerCamelMapper)
+//	size, err := obj.GetSize()
+//
+// The procedure name comes from the field's `xmlrpc:"name"` tag, or from
+// passing the field name through the name mapper (if one is supplied).
+// Fields tagged `xmlrpc:"-"` and fields which are not functions are left
+// alone.
+//
+// Each function must return an error as its final result, which holds any
+// transport error or *Fault.  If the function has one other result, the
+// remote procedure's result is decoded into it; if it has several, the
+// procedure must return an array and each element is decoded into the
+// corresponding result.  A first argument of type context.Context is used
+// for the call rather than sent to the server, and variadic arguments are
+// sent as separate parameters.
+func (c *Client) Bind(stub interface{}, mapper func(string) string) error {
+	sv := reflect.ValueOf(stub)
+	if sv.Kind() != reflect.Ptr || sv.IsNil() ||
+		sv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Cannot bind %T; not a pointer to a struct", stub)
+	}
+
+	sv = sv.Elem()
+	st := sv.Type()
+
+	fns := make(map[int]reflect.Value)
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if f.PkgPath != "" || f.Type.Kind() != reflect.Func {
+			continue
+		}
+
+		name := f.Tag.Get("xmlrpc")
+		if name == "-" {
+			continue
+		} else if name == "" {
+			name = f.Name
+			if mapper != nil {
+				name = mapper(name)
+			}
+		}
+
+		if name == "" {
+			continue
+		}
+
+		fn, err := c.makeStub(name, f.Type)
+		if err != nil {
+			return fmt.Errorf("Cannot bind %s: %v", f.Name, err)
+		}
+
+		fns[i] = fn
+	}
+
+	// only change the struct once every field has been checked
+	for i, fn := range fns {
+		sv.Field(i).Set(fn)
+	}
+
+	return nil
+}
+
+// create a function of type 'ft' which calls the remote procedure
+func (c *Client) makeStub(methodName string,
+	ft reflect.Type) (reflect.Value, error) {
+	numOut := ft.NumOut()
+	if numOut == 0 || ft.Out(numOut-1) != errorType {
+		return reflect.Value{}, fmt.Errorf("%v does not return an error",
+			ft)
+	}
+
+	firstArg := 0
+	if ft.NumIn() > 0 && ft.In(0) == contextType {
+		firstArg = 1
+	}
+
+	// build the results for a failed call
+	failure := func(err error) []reflect.Value {
+		out := make([]reflect.Value, numOut)
+		for i := 0; i < numOut-1; i++ {
+			out[i] = reflect.Zero(ft.Out(i))
+		}
+
+		out[numOut-1] = reflect.New(errorType).Elem()
+		out[numOut-1].Set(reflect.ValueOf(err))
+		return out
+	}
+
+	stub := func(in []reflect.Value) []reflect.Value {
+		ctx := context.Background()
+		if firstArg > 0 && !in[0].IsNil() {
+			ctx = in[0].Interface().(context.Context)
+		}
+
+		var args []interface{}
+		for i := firstArg; i < len(in); i++ {
+			if ft.IsVariadic() && i == len(in)-1 {
+				for j := 0; j < in[i].Len(); j++ {
+					args = append(args, in[i].Index(j).Interface())
+				}
+				break
+			}
+
+			args = append(args, in[i].Interface())
+		}
+
+		val, err, fault := c.call(ctx, methodName, args)
+		if err != nil {
+			return failure(err)
+		} else if fault != nil {
+			return failure(fault)
+		}
+
+		out := make([]reflect.Value, numOut)
+		out[numOut-1] = reflect.Zero(errorType)
+
+		var vals []interface{}
+		if numOut == 2 {
+			vals = []interface{}{val}
+		} else if numOut > 2 {
+			var ok bool
+			if vals, ok = val.([]interface{}); !ok ||
+				len(vals) != numOut-1 {
+				return failure(fmt.Errorf("%s returned %v, expected"+
+					" %d values", methodName, val, numOut-1))
+			}
+		}
+
+		for i, v := range vals {
+			rv, cerr := convertValue(v, ft.Out(i),
+				fmt.Sprintf("result[%d]", i))
+			if cerr != nil {
+				return failure(cerr)
+			}
+
+			out[i] = rv
+		}
+
+		return out
+	}
+
+	return reflect.MakeFunc(ft, stub), nil
+}
@@ -0,0 +1,126 @@
+package xmlrpc
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+type sizerStub struct {
+	GetSize func() (int, error)
+	SetSize func(size int) error
+	Fail    func(ctx context.Context, msg string) error
+	Sum     func(nums ...int) (int64, error) `xmlrpc:"math.sum"`
+	Split   func(s string) (string, string, error)
+	Ignored func() error `xmlrpc:"-"`
+	Value   int
+}
+
+func TestBind(t *testing.T) {
+	obj := &sizer{}
+
+	h := NewHandler()
+	if err := h.Register(obj, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	h.RegisterFunc("math.sum", func(nums ...int) int {
+		total := 0
+		for _, n := range nums {
+			total += n
+		}
+		return total
+	}, false)
+	h.RegisterFunc("Split", func(s string) []string {
+		return strings.SplitN(s, ":", 2)
+	}, false)
+
+	client, closer := newTestClient(t, h)
+	defer closer()
+
+	var stub sizerStub
+	if err := client.Bind(&stub, nil); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	if stub.Ignored != nil {
+		t.Fatal("Bind filled in a field tagged \"-\"")
+	}
+
+	if err := stub.SetSize(42); err != nil {
+		t.Fatalf("SetSize failed: %v", err)
+	}
+
+	if size, err := stub.GetSize(); err != nil {
+		t.Fatalf("GetSize failed: %v", err)
+	} else if size != 42 {
+		t.Fatalf("GetSize returned %d, not 42", size)
+	}
+
+	err := stub.Fail(context.Background(), "oops")
+	if fault, ok := err.(*Fault); !ok {
+		t.Fatalf("Fail returned %v, not a *Fault", err)
+	} else if fault.Code != 17 || fault.Msg != "oops" {
+		t.Fatalf("Fail returned unexpected fault %v", fault)
+	}
+
+	if sum, err := stub.Sum(1, 2, 3); err != nil {
+		t.Fatalf("Sum failed: %v", err)
+	} else if sum != 6 {
+		t.Fatalf("Sum returned %d, not 6", sum)
+	}
+
+	if a, b, err := stub.Split("abc:def"); err != nil {
+		t.Fatalf("Split failed: %v", err)
+	} else if a != "abc" || b != "def" {
+		t.Fatalf("Split returned (%s, %s), not (abc, def)", a, b)
+	}
+
+	if _, _, err := stub.Split("abc"); err == nil {
+		t.Fatal("Split did not report a short result array")
+	}
+}
+
+func TestBindMapper(t *testing.T) {
+	h := NewHandler()
+	if err := h.RegisterName("sizer", &sizer{size: 9}, false); err != nil {
+		t.Fatalf("RegisterName failed: %v", err)
+	}
+
+	client, closer := newTestClient(t, h)
+	defer closer()
+
+	var stub struct {
+		GetSize func() (int, error)
+	}
+
+	err := client.Bind(&stub, ChainMappers(LowerCamelMapper,
+		PrefixMapper("sizer.")))
+	if err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	if size, err := stub.GetSize(); err != nil {
+		t.Fatalf("GetSize failed: %v", err)
+	} else if size != 9 {
+		t.Fatalf("GetSize returned %d, not 9", size)
+	}
+}
+
+func TestBindBadStub(t *testing.T) {
+	client := &Client{urlStr: "http://localhost/RPC2"}
+
+	var stub sizerStub
+	if err := client.Bind(stub, nil); err == nil {
+		t.Fatal("Bind accepted a non-pointer")
+	}
+
+	var noErr struct {
+		GetSize func() int
+		SetSize func(int) error
+	}
+	if err := client.Bind(&noErr, nil); err == nil {
+		t.Fatal("Bind accepted a function without an error result")
+	} else if noErr.SetSize != nil {
+		t.Fatal("Failed Bind changed the stub")
+	}
+}
@@ -0,0 +1,121 @@
+package xmlrpc
+
+import (
+	"context"
+	"log"
+)
+
+// A Call represents an active or completed asynchronous XML-RPC call
+// started with Client.Go
+type Call struct {
+	MethodName string
+	Args       []interface{}
+
+	// the pointer passed to Go, which is filled in with the decoded
+	// result, or the raw result if Go was passed a nil reply
+	Reply interface{}
+
+	// set if the call failed or the server returned a fault
+	Error error
+	Fault *Fault
+
+	// receives the Call when it completes
+	Done chan *Call
+}
+
+// send the completed call to the Done channel
+func (call *Call) done() {
+	select {
+	case call.Done <- call:
+		// ok
+	default:
+		// don't block the client if the channel is full
+		log.Printf("xmlrpc: discarding %s reply due to insufficient Done"+
+			" channel capacity", call.MethodName)
+	}
+}
+
+// limit the number of requests the client sends at once, blocking any
+// further calls until an earlier one completes
+func WithMaxInFlight(n int) ClientOption {
+	return func(c *Client) error {
+		if n <= 0 {
+			c.inFlight = nil
+			return nil
+		}
+
+		c.inFlight = make(chan struct{}, n)
+
+		// keep enough idle connections to serve all the requests
+		if tr, err := c.httpTransport(); err == nil &&
+			tr.MaxIdleConnsPerHost < n {
+			tr.MaxIdleConnsPerHost = n
+		}
+
+		return nil
+	}
+}
+
+// wait for a free in-flight request slot, returning false if the context
+// is cancelled first
+func (c *Client) acquire(ctx context.Context) bool {
+	if c.inFlight == nil {
+		return true
+	}
+
+	select {
+	case c.inFlight <- struct{}{}:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// free an in-flight request slot
+func (c *Client) release() {
+	if c.inFlight != nil {
+		<-c.inFlight
+	}
+}
+
+// start an asynchronous call, returning the Call immediately
+//
+// If 'reply' is not nil, it must be a pointer and the result is decoded
+// into it.  The Call is sent to 'done' when it completes.  If 'done' is
+// nil a new channel is allocated, otherwise it must be buffered.
+func (c *Client) Go(methodName string, args []interface{}, reply interface{},
+	done chan *Call) *Call {
+	return c.GoContext(context.Background(), methodName, args, reply, done)
+}
+
+// start an asynchronous call which is abandoned if the context is
+// cancelled
+func (c *Client) GoContext(ctx context.Context, methodName string,
+	args []interface{}, reply interface{}, done chan *Call) *Call {
+	if done == nil {
+		done = make(chan *Call, 10)
+	} else if cap(done) == 0 {
+		log.Panic("xmlrpc: done channel is unbuffered")
+	}
+
+	call := &Call{MethodName: methodName, Args: args, Reply: reply,
+		Done: done}
+
+	go func() {
+		val, err, fault := c.call(ctx, methodName, args)
+		if err != nil {
+			call.Error = err
+		} else if fault != nil {
+			call.Fault = fault
+			call.Error = fault
+		} else if reply == nil {
+			call.Reply = val
+		} else {
+			call.Error = Decode(val, reply)
+		}
+
+		call.done()
+	}()
+
+	return call
+}
@@ -0,0 +1,120 @@
+package xmlrpc
+
+import (
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientGo(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("square", func(i int) int { return i * i }, false)
+	h.RegisterFunc("split", func(s string) []string {
+		return []string{s[:1], s[1:]}
+	}, false)
+	h.RegisterFunc("fail", func() *Fault { return NewFault(3, "Boom") },
+		false)
+
+	client, done := newTestClient(t, h)
+	defer done()
+
+	const numCalls = 20
+
+	results := make(chan *Call, numCalls)
+	for i := 0; i < numCalls; i++ {
+		client.Go("square", []interface{}{i}, nil, results)
+	}
+
+	sum := 0
+	for i := 0; i < numCalls; i++ {
+		call := <-results
+		if call.Error != nil {
+			t.Fatalf("square returned error %v", call.Error)
+		}
+		sum += call.Reply.(int)
+	}
+
+	if sum != 2470 {
+		t.Fatalf("Sum of squares is %d, not 2470", sum)
+	}
+
+	var parts []string
+	call := <-client.Go("split", []interface{}{"abc"}, &parts, nil).Done
+	if call.Error != nil {
+		t.Fatalf("split returned error %v", call.Error)
+	} else if call.Reply != &parts || len(parts) != 2 || parts[1] != "bc" {
+		t.Fatalf("split returned %v", parts)
+	}
+
+	var num int
+	call = <-client.Go("split", []interface{}{"abc"}, &num, nil).Done
+	if call.Error == nil {
+		t.Fatal("Decoding an array into an int did not fail")
+	}
+
+	call = <-client.Go("fail", nil, nil, nil).Done
+	if call.Fault == nil || call.Fault.Code != 3 {
+		t.Fatalf("fail returned fault %v", call.Fault)
+	} else if call.Error != call.Fault {
+		t.Fatalf("fail returned error %v, not the fault", call.Error)
+	}
+}
+
+func TestClientGoUnbuffered(t *testing.T) {
+	client := &Client{}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Go accepted an unbuffered done channel")
+		}
+	}()
+
+	client.Go("foo", nil, nil, make(chan *Call))
+}
+
+func TestClientMaxInFlight(t *testing.T) {
+	var mutex sync.Mutex
+	active, maxActive := 0, 0
+
+	h := NewHandler()
+	h.RegisterFunc("slow", func() {
+		mutex.Lock()
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mutex.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mutex.Lock()
+		active--
+		mutex.Unlock()
+	}, false)
+
+	srvr := httptest.NewServer(h)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL, WithMaxInFlight(3))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	const numCalls = 12
+
+	results := make(chan *Call, numCalls)
+	for i := 0; i < numCalls; i++ {
+		client.Go("slow", nil, nil, results)
+	}
+
+	for i := 0; i < numCalls; i++ {
+		if call := <-results; call.Error != nil {
+			t.Fatalf("slow returned error %v", call.Error)
+		}
+	}
+
+	if maxActive > 3 {
+		t.Fatalf("Server saw %d concurrent calls, more than 3", maxActive)
+	}
+}
@@ -0,0 +1,226 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+	"unicode/utf8"
+)
+
+// an 8-bit character set which matches ASCII in its lower half
+type charset struct {
+	// name used in XML declarations and Content-Type headers
+	name string
+
+	// characters for bytes 0x80-0xFF (or -1 if the byte is invalid)
+	high [128]rune
+}
+
+// characters for bytes 0x80-0x9F in Windows-1252; the five unused bytes
+// map to the matching C1 control characters as they do in browsers
+var cp1252High = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+// supported character sets other than UTF-8
+var (
+	charsetASCII  = &charset{name: "US-ASCII"}
+	charsetLatin1 = &charset{name: "ISO-8859-1"}
+	charset1252   = &charset{name: "windows-1252"}
+)
+
+func init() {
+	for i := range charsetASCII.high {
+		charsetASCII.high[i] = -1
+		charsetLatin1.high[i] = rune(0x80 + i)
+		charset1252.high[i] = rune(0x80 + i)
+	}
+	copy(charset1252.high[:], cp1252High[:])
+}
+
+// return the character set named by 'label', or nil for UTF-8
+func lookupCharset(label string) (*charset, error) {
+	switch strings.ToLower(strings.TrimSpace(label)) {
+	case "", "utf-8", "utf8":
+		return nil, nil
+	case "us-ascii", "ascii", "iso646-us", "ansi_x3.4-1968":
+		return charsetASCII, nil
+	case "iso-8859-1", "iso8859-1", "iso_8859-1", "latin1", "l1",
+		"cp819":
+		return charsetLatin1, nil
+	case "windows-1252", "cp1252", "x-cp1252":
+		return charset1252, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported charset \"%s\"", label)
+}
+
+// return the byte for a character, or false if the character set can't
+// represent it
+func (cs *charset) encodeRune(r rune) (byte, bool) {
+	if r < 0x80 {
+		return byte(r), true
+	}
+
+	for i, hr := range cs.high {
+		if hr == r {
+			return byte(0x80 + i), true
+		}
+	}
+
+	return 0, false
+}
+
+// a reader which converts text in an 8-bit character set to UTF-8
+type charsetReader struct {
+	r       io.Reader
+	cs      *charset
+	in      []byte
+	pending []byte
+	err     error
+}
+
+func (cr *charsetReader) Read(p []byte) (int, error) {
+	for len(cr.pending) == 0 {
+		if cr.err != nil {
+			return 0, cr.err
+		}
+
+		if cr.in == nil {
+			cr.in = make([]byte, 4096)
+		}
+
+		n, err := cr.r.Read(cr.in)
+		for _, b := range cr.in[:n] {
+			if b < 0x80 {
+				cr.pending = append(cr.pending, b)
+			} else if r := cr.cs.high[b-0x80]; r >= 0 {
+				cr.pending = utf8.AppendRune(cr.pending, r)
+			} else {
+				err = fmt.Errorf("Invalid %s byte 0x%02x", cr.cs.name, b)
+				break
+			}
+		}
+		cr.err = err
+	}
+
+	n := copy(p, cr.pending)
+	cr.pending = cr.pending[n:]
+	return n, nil
+}
+
+// return a reader which converts text in the named character set to UTF-8
+// (used as an xml.Decoder's CharsetReader)
+func newCharsetReader(label string, input io.Reader) (io.Reader, error) {
+	cs, err := lookupCharset(label)
+	if err != nil {
+		return nil, err
+	} else if cs == nil {
+		return input, nil
+	}
+
+	return &charsetReader{r: input, cs: cs}, nil
+}
+
+// a writer which converts UTF-8 text to an 8-bit character set, writing
+// characters which can't be represented as XML character references
+//
+// Only text written between tags may contain such characters, and each
+// Write must hold complete UTF-8 sequences.
+type charsetWriter struct {
+	w  io.Writer
+	cs *charset
+}
+
+func (cw *charsetWriter) Write(p []byte) (int, error) {
+	var buf bytes.Buffer
+	for i := 0; i < len(p); {
+		r, size := utf8.DecodeRune(p[i:])
+		i += size
+
+		if b, ok := cw.cs.encodeRune(r); ok {
+			buf.WriteByte(b)
+		} else {
+			fmt.Fprintf(&buf, "&#%d;", r)
+		}
+	}
+
+	if _, err := buf.WriteTo(cw.w); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// return the charset parameter of a Content-Type header, or ""
+func contentCharset(ctype string) string {
+	_, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return ""
+	}
+
+	return params["charset"]
+}
+
+// create an XML decoder for a document whose character set is given by
+// the XML declaration, unless 'label' (usually the charset from a
+// Content-Type header) overrides it
+func newXMLDecoder(r io.Reader, label string) (*xml.Decoder, error) {
+	cs, err := lookupCharset(label)
+	if err != nil {
+		return nil, err
+	} else if cs == nil {
+		p := xml.NewDecoder(r)
+		if strings.TrimSpace(label) == "" {
+			p.CharsetReader = newCharsetReader
+		} else {
+			// the text is already UTF-8
+			p.CharsetReader = ignoreCharset
+		}
+		return p, nil
+	}
+
+	// the text is converted to UTF-8 before the decoder sees it, so the
+	// encoding in the XML declaration must be ignored
+	p := xml.NewDecoder(&charsetReader{r: r, cs: cs})
+	p.CharsetReader = ignoreCharset
+	return p, nil
+}
+
+// return the input unchanged whatever the XML declaration says (used as
+// an xml.Decoder's CharsetReader when the character set is already known)
+func ignoreCharset(label string, input io.Reader) (io.Reader, error) {
+	return input, nil
+}
+
+// return an XML declaration naming the character set (or with no
+// encoding if 'cs' is nil)
+func xmlDeclaration(cs *charset) string {
+	if cs == nil {
+		return "<?xml version=\"1.0\"?>"
+	}
+
+	return "<?xml version=\"1.0\" encoding=\"" + cs.name + "\"?>"
+}
+
+// convert a document written by marshalArray to the character set,
+// replacing its XML declaration with one naming the character set
+func encodeCharset(data []byte, cs *charset) []byte {
+	if cs == nil {
+		return data
+	}
+
+	decl := []byte(xmlDeclaration(nil))
+	data = bytes.TrimPrefix(data, decl)
+
+	var buf bytes.Buffer
+	buf.WriteString(xmlDeclaration(cs))
+	(&charsetWriter{w: &buf, cs: cs}).Write(data)
+	return buf.Bytes()
+}
@@ -0,0 +1,229 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUnmarshalDeclaredCharset(t *testing.T) {
+	var tests = []struct {
+		encoding string
+		text     string
+		expected string
+	}{
+		{"ISO-8859-1", "caf\xe9 \xa3", "café £"},
+		{"latin1", "\x80", "\u0080"},
+		{"windows-1252", "\x80 \x93hi\x94", "€ “hi”"},
+		{"US-ASCII", "plain", "plain"},
+		{"UTF-8", "café", "café"},
+	}
+
+	for _, test := range tests {
+		xmlStr := `<?xml version="1.0" encoding="` + test.encoding + `"?>
+<methodResponse><params><param><value><string>` + test.text +
+			`</string></value></param></params></methodResponse>`
+
+		_, val, err, fault := UnmarshalString(xmlStr)
+		if err != nil || fault != nil {
+			t.Errorf("%s: returned %v, %v", test.encoding, err, fault)
+		} else if val != test.expected {
+			t.Errorf("%s: returned %q, not %q", test.encoding, val,
+				test.expected)
+		}
+
+		doc, err := ParseDocumentString(xmlStr)
+		if err != nil {
+			t.Errorf("%s: ParseDocument failed: %v", test.encoding, err)
+		} else if doc.Params[0].Text != test.expected {
+			t.Errorf("%s: ParseDocument returned %q", test.encoding,
+				doc.Params[0].Text)
+		}
+	}
+}
+
+func TestUnmarshalBadCharset(t *testing.T) {
+	for _, xmlStr := range []string{
+		`<?xml version="1.0" encoding="KOI8-R"?><methodResponse/>`,
+		`<?xml version="1.0" encoding="US-ASCII"?><methodResponse><params>` +
+			"<param><value>caf\xe9</value></param></params></methodResponse>",
+	} {
+		if _, _, err, _ := UnmarshalString(xmlStr); err == nil {
+			t.Errorf("Unmarshal accepted %q", xmlStr)
+		}
+	}
+}
+
+// start a server which echoes a string
+func newEchoServer() (*httptest.Server, *requestLog) {
+	h := NewHandler()
+	h.RegisterFunc("echo", func(s string) string { return s }, false)
+
+	return newRecordingServer(func(w http.ResponseWriter, r *http.Request,
+		n int) {
+		h.ServeHTTP(w, r)
+	})
+}
+
+func TestHandlerContentTypeCharset(t *testing.T) {
+	srvr, _ := newEchoServer()
+	defer srvr.Close()
+
+	// the Content-Type charset overrides the XML declaration, even
+	// when it's UTF-8
+	var tests = []struct {
+		charset  string
+		decl     string
+		text     string
+		expected string
+	}{
+		{"windows-1252", "UTF-8", "\x80 caf\xe9", "€ café"},
+		{"utf-8", "ISO-8859-1", "€ café", "€ café"},
+	}
+
+	for _, test := range tests {
+		body := `<?xml version="1.0" encoding="` + test.decl + `"?>` +
+			"<methodCall><methodName>echo</methodName><params><param>" +
+			"<value>" + test.text + "</value></param></params></methodCall>"
+
+		resp, err := http.Post(srvr.URL, "text/xml; charset="+test.charset,
+			strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, val, err, fault := Unmarshal(resp.Body)
+		resp.Body.Close()
+		if err != nil || fault != nil {
+			t.Fatalf("%s: returned %v, %v", test.charset, err, fault)
+		} else if val != test.expected {
+			t.Fatalf("%s: returned %q, not %q", test.charset, val,
+				test.expected)
+		}
+	}
+
+	body := `<?xml version="1.0"?><methodCall>` +
+		"<methodName>echo</methodName><params><param><value>" +
+		"caf\xe9</value></param></params></methodCall>"
+
+	resp, err := http.Post(srvr.URL, "text/xml; charset=EBCDIC",
+		strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, _, err, fault := Unmarshal(resp.Body); err != nil ||
+		fault == nil || fault.Code != errNotWellFormed {
+		t.Fatalf("Unknown charset returned %v, %v", err, fault)
+	}
+}
+
+func TestClientEncoding(t *testing.T) {
+	srvr, reqs := newEchoServer()
+	defer srvr.Close()
+
+	if _, err := NewClientURL(srvr.URL, WithEncoding("EBCDIC")); err == nil {
+		t.Fatal("WithEncoding accepted an unknown charset")
+	}
+
+	client, err := NewClientURL(srvr.URL, WithEncoding("ISO-8859-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const str = "café costs €3 <cheap>"
+	if val, err, fault := client.RPCCall("echo", str); err != nil ||
+		fault != nil {
+		t.Fatalf("Call failed: %v, %v", err, fault)
+	} else if val != str {
+		t.Fatalf("Returned %q, not %q", val, str)
+	}
+
+	last := reqs.last()
+	if ctype := last.header.Get("Content-Type"); ctype !=
+		"text/xml; charset=ISO-8859-1" {
+		t.Errorf("Content-Type is %q", ctype)
+	}
+
+	if !bytes.HasPrefix(last.body,
+		[]byte(`<?xml version="1.0" encoding="ISO-8859-1"?>`)) {
+		t.Errorf("Request has no encoding declaration: %q", last.body)
+	} else if !bytes.Contains(last.body, []byte("caf\xe9 costs &#8364;3")) {
+		t.Errorf("Request is not ISO-8859-1: %q", last.body)
+	}
+}
+
+func TestClientResponseCharset(t *testing.T) {
+	srvr := httptest.NewServer(http.HandlerFunc(func(resp http.ResponseWriter,
+		req *http.Request) {
+		resp.Header().Set("Content-Type", "text/xml; charset=ISO-8859-1")
+		io.WriteString(resp, "<methodResponse><params><param><value>"+
+			"\xbfqu\xe9?</value></param></params></methodResponse>")
+	}))
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if val, err, fault := client.RPCCall("any"); err != nil || fault != nil {
+		t.Fatalf("Call failed: %v, %v", err, fault)
+	} else if val != "¿qué?" {
+		t.Fatalf("Returned %q", val)
+	}
+}
+
+func TestEncoderEncoding(t *testing.T) {
+	doc := &Document{MethodName: "naïve",
+		Params: []*Value{{Kind: KindString, Text: "“déjà vu” & €"}}}
+
+	var tests = []struct {
+		encoding string
+		decl     string
+		expected string
+	}{
+		{"", `<?xml version="1.0"?>`, "“déjà vu” &amp; €"},
+		{"utf-8", `<?xml version="1.0" encoding="UTF-8"?>`,
+			"“déjà vu” &amp; €"},
+		{"iso-8859-1", `<?xml version="1.0" encoding="ISO-8859-1"?>`,
+			"&#8220;d\xe9j\xe0 vu&#8221; &amp; &#8364;"},
+		{"cp1252", `<?xml version="1.0" encoding="windows-1252"?>`,
+			"\x93d\xe9j\xe0 vu\x94 &amp; \x80"},
+		{"ascii", `<?xml version="1.0" encoding="US-ASCII"?>`,
+			"&#8220;d&#233;j&#224; vu&#8221; &amp; &#8364;"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		e := NewEncoder(&buf)
+		if err := e.SetEncoding(test.encoding); err != nil {
+			t.Fatalf("%s: SetEncoding failed: %v", test.encoding, err)
+		} else if err = e.Encode(doc); err != nil {
+			t.Fatalf("%s: Encode failed: %v", test.encoding, err)
+		}
+
+		out := buf.String()
+		if !strings.HasPrefix(out, test.decl) {
+			t.Errorf("%s: output starts %q", test.encoding, out)
+		} else if !strings.Contains(out, "<string>"+test.expected+
+			"</string>") {
+			t.Errorf("%s: output is %q", test.encoding, out)
+		}
+
+		ndoc, err := ParseDocument(&buf)
+		if err != nil {
+			t.Fatalf("%s: cannot parse output: %v", test.encoding, err)
+		} else if diff := Diff(doc, ndoc); diff != "" {
+			t.Errorf("%s: output differs: %s", test.encoding, diff)
+		}
+	}
+
+	if err := NewEncoder(io.Discard).SetEncoding("EBCDIC"); err == nil {
+		t.Fatal("SetEncoding accepted an unknown charset")
+	}
+}
@@ -0,0 +1,651 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"sync"
+)
+
+// ClientInvoker sends an XML-RPC request and returns the decoded result
+type ClientInvoker func(ctx context.Context, methodName string,
+	args []interface{}) (interface{}, error, *Fault)
+
+// A ClientInterceptor wraps every call made by a Client.  It can examine
+// or rewrite the method name and arguments, retry or stub out the call by
+// not invoking 'invoker', and examine or rewrite the result.
+type ClientInterceptor func(ctx context.Context, methodName string,
+	args []interface{}, invoker ClientInvoker) (interface{}, error, *Fault)
+
+// XML-RPC client data
+type Client struct {
+	http.Client
+	urlStr string
+
+	// HTTP Basic authentication credentials
+	hasAuth  bool
+	username string
+	password string
+
+	// true once the client has its own copy of the HTTP transport
+	ownTransport bool
+
+	// TLS configuration, extra PEM-encoded certificate authorities and
+	// a dialer which opens connections instead of the transport's own;
+	// they're installed after all the options have run, so a later
+	// option which replaces the transport can't drop them
+	tlsConfig *tls.Config
+	caBundles [][]byte
+	dial      func(ctx context.Context, network, addr string) (net.Conn,
+		error)
+
+	// guards the fields below
+	mutex        sync.Mutex
+	interceptors []ClientInterceptor
+
+	// headers added to every request
+	header http.Header
+
+	// headers from the most recent response
+	lastHeader http.Header
+
+	// limits the number of requests in flight (nil if unlimited)
+	inFlight chan struct{}
+
+	// retry policy and the names of the methods it applies to
+	retry      *RetryPolicy
+	idempotent map[string]bool
+
+	// if true, send JSON-RPC 2.0 requests instead of XML-RPC
+	jsonRPC bool
+
+	// id of the most recent JSON-RPC request
+	nextID uint64
+
+	// if true, ask for compressed responses and gzip requests of at
+	// least compressMin bytes (unless compressMin is negative)
+	compress    bool
+	compressMin int
+
+	// limit on the decompressed size of a response body
+	maxResponse int64
+
+	// character set used for requests (nil for UTF-8)
+	charset *charset
+}
+
+// A ClientOption configures a Client created by NewClientURL
+type ClientOption func(*Client) error
+
+// connect to a remote XML-RPC server
+func NewClient(host string, port int) (*Client, error) {
+	return NewClientURL(fmt.Sprintf("http://%s:%d/RPC2", host, port))
+}
+
+// connect to the remote XML-RPC server at 'rawURL'
+//
+// The URL can use either "http" or "https" and can include a path and
+// query string (a URL without a path uses "/RPC2").  Any user name and
+// password in the URL are removed and sent using HTTP Basic
+// authentication.
+//
+// A "unix" URL such as "unix:///var/run/supervisor.sock" connects
+// through a Unix domain socket and sends requests for "/RPC2"; use
+// WithUnixSocket with an "http" URL to send requests for another path.
+func NewClientURL(rawURL string, opts ...ClientOption) (*Client, error) {
+	uurl, uerr := url.Parse(rawURL)
+	if uerr != nil {
+		return nil, uerr
+	}
+
+	var socketPath string
+	if uurl.Scheme == "unix" {
+		if uurl.Path == "" {
+			return nil, fmt.Errorf("No socket path in URL %s", rawURL)
+		}
+
+		socketPath = uurl.Path
+		uurl = &url.URL{Scheme: "http", Host: unixHost, Path: "/RPC2"}
+	}
+
+	if uurl.Scheme != "http" && uurl.Scheme != "https" {
+		return nil, fmt.Errorf("Unsupported URL scheme \"%s\" in %s",
+			uurl.Scheme, rawURL)
+	} else if uurl.Host == "" {
+		return nil, fmt.Errorf("No host in URL %s", rawURL)
+	}
+
+	if uurl.Path == "" {
+		uurl.Path = "/RPC2"
+	}
+
+	c := &Client{maxResponse: DefaultMaxBodySize}
+
+	if uurl.User != nil {
+		c.hasAuth = true
+		c.username = uurl.User.Username()
+		c.password, _ = uurl.User.Password()
+		uurl.User = nil
+	}
+
+	c.urlStr = uurl.String()
+
+	if socketPath != "" {
+		c.dial = unixDialer(socketPath)
+	}
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := c.applyTransportOptions(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// use a copy of the supplied HTTP client's settings (transport, cookie
+// jar, timeout, etc.) for all requests; the TLS options are applied to a
+// copy of its transport whichever order the options are given in
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("Nil HTTP client")
+		}
+
+		c.Client = *hc
+		c.ownTransport = false
+		return nil
+	}
+}
+
+// send requests using the HTTP transport (for example a Recorder)
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		if rt == nil {
+			return errors.New("Nil HTTP transport")
+		}
+
+		c.Transport = rt
+		c.ownTransport = false
+		return nil
+	}
+}
+
+// send XML-RPC requests in the named character set ("ISO-8859-1",
+// "windows-1252" or "US-ASCII") instead of UTF-8, for servers which can't
+// handle anything else; characters outside the set are sent as XML
+// character references
+func WithEncoding(charset string) ClientOption {
+	return func(c *Client) error {
+		cs, err := lookupCharset(charset)
+		if err != nil {
+			return err
+		}
+
+		c.charset = cs
+		return nil
+	}
+}
+
+// send the user name and password using HTTP Basic authentication
+func WithBasicAuth(username, password string) ClientOption {
+	return func(c *Client) error {
+		c.hasAuth = true
+		c.username = username
+		c.password = password
+		return nil
+	}
+}
+
+// use the TLS configuration for "https" connections
+func WithTLSConfig(cfg *tls.Config) ClientOption {
+	return func(c *Client) error {
+		c.tlsConfig = cfg.Clone()
+		return nil
+	}
+}
+
+// trust the PEM-encoded certificate authorities in addition to those in
+// the TLS configuration (or the system's default CAs if the configuration
+// has no RootCAs)
+func WithCABundle(pemCerts []byte) ClientOption {
+	return func(c *Client) error {
+		if !x509.NewCertPool().AppendCertsFromPEM(pemCerts) {
+			return errors.New("No certificates found in CA bundle")
+		}
+
+		c.caBundles = append(c.caBundles, pemCerts)
+		return nil
+	}
+}
+
+// trust the certificate authorities in the PEM file 'path'
+func WithCAFile(path string) ClientOption {
+	return func(c *Client) error {
+		pemCerts, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		return WithCABundle(pemCerts)(c)
+	}
+}
+
+// install the TLS settings and dialer made by the options in the client's
+// own copy of the HTTP transport, failing if the transport can't be
+// configured
+func (c *Client) applyTransportOptions() error {
+	if c.tlsConfig == nil && len(c.caBundles) == 0 && c.dial == nil {
+		return nil
+	}
+
+	tr, err := c.httpTransport()
+	if err != nil {
+		return err
+	}
+
+	if c.tlsConfig != nil {
+		tr.TLSClientConfig = c.tlsConfig
+	}
+
+	if len(c.caBundles) > 0 {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+
+		pool := tr.TLSClientConfig.RootCAs
+		if pool == nil {
+			if pool, err = x509.SystemCertPool(); err != nil {
+				pool = x509.NewCertPool()
+			}
+		} else {
+			pool = pool.Clone()
+		}
+
+		for _, pemCerts := range c.caBundles {
+			pool.AppendCertsFromPEM(pemCerts)
+		}
+		tr.TLSClientConfig.RootCAs = pool
+	}
+
+	if c.dial != nil {
+		tr.DialContext = c.dial
+		tr.DialTLSContext = nil
+	}
+
+	return nil
+}
+
+// add a header to every request sent by the client
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) error {
+		c.AddHeader(key, value)
+		return nil
+	}
+}
+
+// keep cookies set by the server in the jar and send them with later
+// requests, so a session established by a login call is reused
+func WithCookieJar(jar http.CookieJar) ClientOption {
+	return func(c *Client) error {
+		c.Jar = jar
+		return nil
+	}
+}
+
+// keep cookies set by the server in a new in-memory cookie jar
+func WithCookies() ClientOption {
+	return func(c *Client) error {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			return err
+		}
+
+		c.Jar = jar
+		return nil
+	}
+}
+
+// return an HTTP transport which can be modified without affecting any
+// other client
+func (c *Client) httpTransport() (*http.Transport, error) {
+	if c.ownTransport {
+		return c.Transport.(*http.Transport), nil
+	}
+
+	var tr *http.Transport
+	if c.Transport == nil {
+		tr = http.DefaultTransport.(*http.Transport).Clone()
+	} else if orig, ok := c.Transport.(*http.Transport); ok {
+		tr = orig.Clone()
+	} else {
+		return nil, fmt.Errorf("Cannot configure HTTP transport %T",
+			c.Transport)
+	}
+
+	c.Transport = tr
+	c.ownTransport = true
+
+	return tr, nil
+}
+
+// add interceptors to the client
+//
+// Interceptors run in the order they were added, so the first one added
+// sees the call first and the result last.  A Multicall batch passes
+// through the interceptors as a single "system.multicall" call.
+func (c *Client) Use(interceptors ...ClientInterceptor) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ilist := make([]ClientInterceptor, 0,
+		len(c.interceptors)+len(interceptors))
+	ilist = append(ilist, c.interceptors...)
+	c.interceptors = append(ilist, interceptors...)
+}
+
+// set a header sent with every request, replacing any earlier values
+func (c *Client) SetHeader(key, value string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.header == nil {
+		c.header = make(http.Header)
+	}
+	c.header.Set(key, value)
+}
+
+// add a header sent with every request
+func (c *Client) AddHeader(key, value string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.header == nil {
+		c.header = make(http.Header)
+	}
+	c.header.Add(key, value)
+}
+
+// stop sending a header with every request
+func (c *Client) DelHeader(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.header.Del(key)
+}
+
+// return the headers of the most recent response (or nil if no response
+// has been received)
+func (c *Client) LastResponseHeader() http.Header {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.lastHeader.Clone()
+}
+
+// context key for per-call headers
+type callHeaderKey struct{}
+
+// return a copy of the context which adds a header to any call made with
+// it, overriding any client header with the same key
+func ContextWithHeader(ctx context.Context, key,
+	value string) context.Context {
+	hdr := make(http.Header)
+	if old, ok := ctx.Value(callHeaderKey{}).(http.Header); ok {
+		hdr = old.Clone()
+	}
+	hdr.Add(key, value)
+
+	return context.WithValue(ctx, callHeaderKey{}, hdr)
+}
+
+// add the client and per-call headers to the request
+func (c *Client) addHeaders(req *http.Request) {
+	c.mutex.Lock()
+	for key, vals := range c.header {
+		req.Header[key] = append([]string(nil), vals...)
+	}
+	c.mutex.Unlock()
+
+	if hdr, ok := req.Context().Value(callHeaderKey{}).(http.Header); ok {
+		for key, vals := range hdr {
+			req.Header[key] = append([]string(nil), vals...)
+		}
+	}
+}
+
+// call a procedure on a remote XML-RPC server
+func (c *Client) RPCCall(methodName string,
+	args ...interface{}) (interface{}, error, *Fault) {
+	return c.RPCCallContext(context.Background(), methodName, args...)
+}
+
+// call a procedure on a remote XML-RPC server, abandoning the request if
+// the context is cancelled
+func (c *Client) RPCCallContext(ctx context.Context, methodName string,
+	args ...interface{}) (interface{}, error, *Fault) {
+	return c.call(ctx, methodName, args)
+}
+
+// run a call through the interceptors and then send it to the server
+func (c *Client) call(ctx context.Context, methodName string,
+	args []interface{}) (interface{}, error, *Fault) {
+	c.mutex.Lock()
+	ilist := c.interceptors
+	c.mutex.Unlock()
+
+	next := ClientInvoker(c.invoke)
+	for i := len(ilist) - 1; i >= 0; i-- {
+		icpt, inner := ilist[i], next
+		next = func(ctx context.Context, methodName string,
+			args []interface{}) (interface{}, error, *Fault) {
+			return icpt(ctx, methodName, args, inner)
+		}
+	}
+
+	return next(ctx, methodName, args)
+}
+
+// send a request to the server and decode the response, retrying
+// idempotent methods as allowed by the retry policy
+func (c *Client) invoke(ctx context.Context, methodName string,
+	args []interface{}) (interface{}, error, *Fault) {
+
+	var body []byte
+	if c.jsonRPC {
+		var berr error
+		if body, berr = c.marshalJSONRequest(methodName, args); berr != nil {
+			return nil, berr, nil
+		}
+	} else {
+		buf := bytes.NewBufferString("")
+		if berr := marshalArray(buf, methodName, args); berr != nil {
+			return nil, berr, nil
+		}
+		body = encodeCharset(buf.Bytes(), c.charset)
+	}
+
+	var encoding string
+	if c.compress && c.compressMin >= 0 && len(body) >= c.compressMin {
+		body = gzipBytes(body)
+		encoding = "gzip"
+	}
+
+	if !c.acquire(ctx) {
+		return nil, ctx.Err(), nil
+	}
+	defer c.release()
+
+	policy := c.retryPolicy(methodName)
+	for attempt := 1; ; attempt++ {
+		pval, perr, pfault, status := c.post(ctx, methodName, body,
+			encoding)
+		if policy == nil || attempt >= policy.MaxAttempts ||
+			!policy.shouldRetry(perr, pfault, status) ||
+			!sleepContext(ctx, policy.backoff(attempt)) {
+			return pval, perr, pfault
+		}
+	}
+}
+
+// make a single attempt at sending a request (whose body is compressed
+// with 'encoding' if that isn't empty) to the server, returning the
+// decoded response along with the HTTP status code (or 0 if no response
+// was received)
+func (c *Client) post(ctx context.Context, methodName string, body []byte,
+	encoding string) (interface{}, error, *Fault, int) {
+	req, err := http.NewRequest("POST", c.urlStr, bytes.NewReader(body))
+	if err != nil {
+		return nil, err, nil, 0
+	}
+
+	req = req.WithContext(ctx)
+	if c.jsonRPC {
+		req.Header.Add("Content-Type", "application/json")
+	} else if c.charset != nil {
+		req.Header.Add("Content-Type", "text/xml; charset="+c.charset.name)
+	} else {
+		req.Header.Add("Content-Type", "text/xml")
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	if c.compress {
+		req.Header.Set("Accept-Encoding", acceptEncodings)
+	}
+	if c.hasAuth {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	c.addHeaders(req)
+
+	r, err := c.Do(req)
+	if err != nil {
+		return nil, err, nil, 0
+	} else if r == nil {
+		err = fmt.Errorf("PostString for %s returned nil response\n",
+			methodName)
+		return nil, err, nil, 0
+	}
+
+	// drain and close the body so the connection can be reused
+	defer func() {
+		io.Copy(io.Discard, r.Body)
+		r.Body.Close()
+	}()
+
+	c.mutex.Lock()
+	c.lastHeader = r.Header
+	c.mutex.Unlock()
+
+	rbody, err := decodeBody(r.Body, r.Header.Get("Content-Encoding"),
+		c.maxResponse)
+	if err != nil {
+		return nil, err, nil, r.StatusCode
+	}
+	r.Body = rbody
+
+	if herr := checkResponse(r, c.jsonRPC); herr != nil {
+		return nil, herr, nil, r.StatusCode
+	}
+
+	var pval interface{}
+	var perr error
+	var pfault *Fault
+	if c.jsonRPC {
+		pval, perr, pfault = unmarshalJSONResponse(r.Body)
+	} else {
+		var params []interface{}
+		_, params, perr, pfault = unmarshalCharset(r.Body,
+			contentCharset(r.Header.Get("Content-Type")))
+		if perr == nil {
+			pval = extractParams(params)
+		}
+	}
+
+	return pval, perr, pfault, r.StatusCode
+}
+
+// A MultiCall is a single call in a batch sent with Client.Multicall.
+// After the batch completes, either Result or Fault is filled in.
+type MultiCall struct {
+	MethodName string
+	Params     []interface{}
+
+	Result interface{}
+	Fault  *Fault
+}
+
+// send several calls to the server in a single "system.multicall" request
+//
+// A non-nil error or fault means the batch as a whole failed; otherwise
+// each call's Result or Fault field holds the outcome of that call
+func (c *Client) Multicall(calls ...*MultiCall) (error, *Fault) {
+	return c.MulticallContext(context.Background(), calls...)
+}
+
+// send several calls in a single request, abandoning the request if the
+// context is cancelled
+func (c *Client) MulticallContext(ctx context.Context,
+	calls ...*MultiCall) (error, *Fault) {
+	batch := make([]interface{}, len(calls))
+	for i, mc := range calls {
+		params := mc.Params
+		if params == nil {
+			params = []interface{}{}
+		}
+
+		batch[i] = map[string]interface{}{
+			"methodName": mc.MethodName,
+			"params":     params,
+		}
+	}
+
+	reply, err, fault := c.call(ctx, "system.multicall",
+		[]interface{}{batch})
+	if err != nil || fault != nil {
+		return err, fault
+	}
+
+	results, ok := reply.([]interface{})
+	if !ok || len(results) != len(calls) {
+		return fmt.Errorf("Bad system.multicall reply %v for %d calls",
+			reply, len(calls)), nil
+	}
+
+	for i, res := range results {
+		switch v := res.(type) {
+		case []interface{}:
+			if len(v) != 1 {
+				return fmt.Errorf("Bad system.multicall result #%d: %v", i,
+					v), nil
+			}
+			calls[i].Result = v[0]
+		case map[string]interface{}:
+			code, cok := v["faultCode"].(int)
+			msg, mok := v["faultString"].(string)
+			if !cok || !mok {
+				return fmt.Errorf("Bad system.multicall fault #%d: %v", i,
+					v), nil
+			}
+			calls[i].Fault = NewFault(code, msg)
+		default:
+			return fmt.Errorf("Bad system.multicall result #%d: %v", i,
+				res), nil
+		}
+	}
+
+	return nil, nil
+}
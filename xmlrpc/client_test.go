@@ -0,0 +1,431 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// an http.RoundTripper which isn't an *http.Transport
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// a request received by a recording server
+type recordedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+// the requests received by a recording server
+type requestLog struct {
+	mutex    sync.Mutex
+	requests []recordedRequest
+}
+
+// add a request to the log, returning its position (starting at 1)
+func (l *requestLog) add(req recordedRequest) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.requests = append(l.requests, req)
+	return len(l.requests)
+}
+
+// return the number of requests received
+func (l *requestLog) count() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return len(l.requests)
+}
+
+// return the most recent request
+func (l *requestLog) last() recordedRequest {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if len(l.requests) == 0 {
+		return recordedRequest{}
+	}
+	return l.requests[len(l.requests)-1]
+}
+
+// start a test server which records the headers and body of each request
+// before passing it to 'serve' along with its position (starting at 1)
+func newRecordingServer(serve func(w http.ResponseWriter, r *http.Request,
+	n int)) (*httptest.Server, *requestLog) {
+	reqs := &requestLog{}
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		n := reqs.add(recordedRequest{header: r.Header.Clone(), body: body})
+		serve(w, r, n)
+	}))
+
+	return srvr, reqs
+}
+
+// start a test server for the handler and create a client which talks to it
+func newTestClient(t *testing.T, h *Handler) (*Client, func()) {
+	srvr := httptest.NewServer(h)
+	return &Client{urlStr: srvr.URL}, srvr.Close
+}
+
+type multicallEntry struct {
+	MethodName string        `xmlrpc:"methodName"`
+	Params     []interface{} `xmlrpc:"params"`
+}
+
+// add a simple system.multicall implementation to the handler
+func registerMulticall(t *testing.T, h *Handler) {
+	err := h.RegisterFunc("system.multicall", func(ctx context.Context,
+		calls []multicallEntry) []interface{} {
+		results := make([]interface{}, len(calls))
+		for i, mc := range calls {
+			rtn, fault := h.dispatch(ctx, mc.MethodName, mc.Params)
+			if fault != nil {
+				results[i] = map[string]interface{}{
+					"faultCode":   fault.Code,
+					"faultString": fault.Msg,
+				}
+			} else {
+				results[i] = rtn
+			}
+		}
+		return results
+	}, false)
+	if err != nil {
+		t.Fatalf("Cannot register system.multicall: %v", err)
+	}
+}
+
+func TestClientInterceptors(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("add", func(a, b int) int { return a + b }, false)
+
+	client, done := newTestClient(t, h)
+	defer done()
+
+	var calls []string
+	client.Use(func(ctx context.Context, methodName string,
+		args []interface{}, invoker ClientInvoker) (interface{}, error,
+		*Fault) {
+		calls = append(calls, "first:"+methodName)
+		return invoker(ctx, methodName, args)
+	})
+	client.Use(func(ctx context.Context, methodName string,
+		args []interface{}, invoker ClientInvoker) (interface{}, error,
+		*Fault) {
+		calls = append(calls, "second:"+methodName)
+		if methodName == "stub" {
+			return "stubbed", nil, nil
+		}
+
+		// rewrite the arguments and the result
+		args = append([]interface{}{10}, args[1:]...)
+		val, err, fault := invoker(ctx, methodName, args)
+		if err == nil && fault == nil {
+			val = val.(int) * 100
+		}
+		return val, err, fault
+	})
+
+	val, err, fault := client.RPCCall("add", 1, 2)
+	if err != nil || fault != nil {
+		t.Fatalf("add returned error %v, fault %v", err, fault)
+	} else if val != 1200 {
+		t.Fatalf("add returned %v, not 1200", val)
+	}
+
+	val, err, fault = client.RPCCall("stub")
+	if err != nil || fault != nil {
+		t.Fatalf("stub returned error %v, fault %v", err, fault)
+	} else if val != "stubbed" {
+		t.Fatalf("stub returned %v, not \"stubbed\"", val)
+	}
+
+	expCalls := []string{"first:add", "second:add", "first:stub",
+		"second:stub"}
+	if !reflect.DeepEqual(calls, expCalls) {
+		t.Fatalf("Interceptors saw %v, not %v", calls, expCalls)
+	}
+}
+
+func TestClientMulticall(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("add", func(a, b int) int { return a + b }, false)
+	h.RegisterFunc("fail", func() *Fault { return NewFault(9, "nope") },
+		false)
+	registerMulticall(t, h)
+
+	client, done := newTestClient(t, h)
+	defer done()
+
+	var seen []string
+	client.Use(func(ctx context.Context, methodName string,
+		args []interface{}, invoker ClientInvoker) (interface{}, error,
+		*Fault) {
+		seen = append(seen, methodName)
+		return invoker(ctx, methodName, args)
+	})
+
+	calls := []*MultiCall{
+		{MethodName: "add", Params: []interface{}{1, 2}},
+		{MethodName: "fail"},
+		{MethodName: "add", Params: []interface{}{3, 4}},
+	}
+
+	if err, fault := client.Multicall(calls...); err != nil || fault != nil {
+		t.Fatalf("Multicall returned error %v, fault %v", err, fault)
+	}
+
+	if calls[0].Result != 3 || calls[0].Fault != nil {
+		t.Fatalf("Bad first result %v/%v", calls[0].Result, calls[0].Fault)
+	} else if calls[1].Fault == nil || calls[1].Fault.Code != 9 {
+		t.Fatalf("Bad second result %v/%v", calls[1].Result, calls[1].Fault)
+	} else if calls[2].Result != 7 || calls[2].Fault != nil {
+		t.Fatalf("Bad third result %v/%v", calls[2].Result, calls[2].Fault)
+	}
+
+	if !reflect.DeepEqual(seen, []string{"system.multicall"}) {
+		t.Fatalf("Interceptor saw %v", seen)
+	}
+}
+
+func TestNewClientURL(t *testing.T) {
+	tests := []struct {
+		rawURL string
+		expURL string
+	}{
+		{"http://localhost:1234", "http://localhost:1234/RPC2"},
+		{"https://example.com/xmlrpc.php", "https://example.com/xmlrpc.php"},
+		{"http://example.com/RPC3?key=abc", "http://example.com/RPC3?key=abc"},
+		{"http://user:pw@example.com/", "http://example.com/"},
+	}
+
+	for _, tst := range tests {
+		client, err := NewClientURL(tst.rawURL)
+		if err != nil {
+			t.Fatalf("NewClientURL(%s) failed: %v", tst.rawURL, err)
+		} else if client.urlStr != tst.expURL {
+			t.Fatalf("NewClientURL(%s) used URL %s, not %s", tst.rawURL,
+				client.urlStr, tst.expURL)
+		}
+	}
+
+	for _, rawURL := range []string{"ftp://example.com/", "localhost:80",
+		"http:///RPC2", "http://bad host/"} {
+		if _, err := NewClientURL(rawURL); err == nil {
+			t.Fatalf("NewClientURL accepted \"%s\"", rawURL)
+		}
+	}
+}
+
+func TestClientURLAuthAndPath(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("ping", func() string { return "pong" }, false)
+
+	var gotUser, gotPass, gotPath, gotQuery string
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		gotUser, gotPass, _ = r.BasicAuth()
+		gotPath = r.URL.Path
+		gotQuery = r.URL.RawQuery
+		h.ServeHTTP(w, r)
+	}))
+	defer srvr.Close()
+
+	rawURL := strings.Replace(srvr.URL, "http://", "http://bob:s%3Acret@", 1)
+	client, err := NewClientURL(rawURL + "/xmlrpc.php?site=2")
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	val, err, fault := client.RPCCall("ping")
+	if err != nil || fault != nil {
+		t.Fatalf("ping returned error %v, fault %v", err, fault)
+	} else if val != "pong" {
+		t.Fatalf("ping returned %v", val)
+	}
+
+	if gotUser != "bob" || gotPass != "s:cret" {
+		t.Fatalf("Server saw credentials %s/%s", gotUser, gotPass)
+	} else if gotPath != "/xmlrpc.php" || gotQuery != "site=2" {
+		t.Fatalf("Server saw path %s, query %s", gotPath, gotQuery)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("ping", func() string { return "pong" }, false)
+
+	srvr := httptest.NewTLSServer(h)
+	defer srvr.Close()
+
+	// the test server's certificate isn't trusted by default
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+	if _, err, _ = client.RPCCall("ping"); err == nil {
+		t.Fatal("Call to untrusted server succeeded")
+	}
+
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: srvr.Certificate().Raw})
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err = os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Cannot write CA file: %v", err)
+	}
+
+	tlsConfig := srvr.Client().Transport.(*http.Transport).TLSClientConfig
+
+	// the TLS options survive a later WithHTTPClient
+	for _, opts := range [][]ClientOption{
+		{WithCABundle(caPEM)},
+		{WithCAFile(caFile)},
+		{WithHTTPClient(srvr.Client())},
+		{WithTLSConfig(tlsConfig)},
+		{WithCAFile(caFile), WithHTTPClient(&http.Client{})},
+		{WithTLSConfig(tlsConfig), WithHTTPClient(&http.Client{
+			Transport: &http.Transport{}})},
+	} {
+		client, err = NewClientURL(srvr.URL, opts...)
+		if err != nil {
+			t.Fatalf("NewClientURL failed: %v", err)
+		}
+
+		val, err, fault := client.RPCCall("ping")
+		if err != nil || fault != nil {
+			t.Fatalf("ping returned error %v, fault %v", err, fault)
+		} else if val != "pong" {
+			t.Fatalf("ping returned %v", val)
+		}
+	}
+
+	// a transport which can't be configured is an error
+	_, err = NewClientURL(srvr.URL, WithCABundle(caPEM),
+		WithHTTPClient(&http.Client{
+			Transport: roundTripFunc(http.DefaultTransport.RoundTrip)}))
+	if err == nil {
+		t.Fatal("NewClientURL dropped the CA bundle")
+	}
+
+	_, err = NewClientURL(srvr.URL, WithCABundle([]byte("junk")))
+	if err == nil {
+		t.Fatal("WithCABundle accepted a bundle without certificates")
+	}
+}
+
+func TestClientHeaders(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("ping", func() string { return "pong" }, false)
+
+	var gotHeader http.Header
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		gotHeader = r.Header
+		w.Header().Set("X-Request-Id", "req-"+r.Header.Get("X-Call"))
+		h.ServeHTTP(w, r)
+	}))
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL,
+		WithHeader("User-Agent", "test-agent/1.0"),
+		WithHeader("X-Call", "default"))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+	client.SetHeader("Authorization", "Bearer abc123")
+
+	if hdr := client.LastResponseHeader(); hdr != nil {
+		t.Fatalf("Got response headers %v before first call", hdr)
+	}
+
+	if _, err, fault := client.RPCCall("ping"); err != nil || fault != nil {
+		t.Fatalf("ping returned error %v, fault %v", err, fault)
+	}
+
+	if ua := gotHeader.Get("User-Agent"); ua != "test-agent/1.0" {
+		t.Fatalf("Server saw User-Agent \"%s\"", ua)
+	} else if auth := gotHeader.Get("Authorization"); auth !=
+		"Bearer abc123" {
+		t.Fatalf("Server saw Authorization \"%s\"", auth)
+	}
+
+	if id := client.LastResponseHeader().Get("X-Request-Id"); id !=
+		"req-default" {
+		t.Fatalf("Last response had X-Request-Id \"%s\"", id)
+	}
+
+	ctx := ContextWithHeader(context.Background(), "X-Call", "special")
+	client.DelHeader("Authorization")
+	if _, err, fault := client.RPCCallContext(ctx, "ping"); err != nil ||
+		fault != nil {
+		t.Fatalf("ping returned error %v, fault %v", err, fault)
+	}
+
+	if call := gotHeader.Get("X-Call"); call != "special" {
+		t.Fatalf("Server saw X-Call \"%s\"", call)
+	} else if auth := gotHeader.Get("Authorization"); auth != "" {
+		t.Fatalf("Server saw deleted Authorization \"%s\"", auth)
+	}
+
+	if id := client.LastResponseHeader().Get("X-Request-Id"); id !=
+		"req-special" {
+		t.Fatalf("Last response had X-Request-Id \"%s\"", id)
+	}
+}
+
+func TestClientCookieSession(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("whoami", func(ctx context.Context) string {
+		return ctx.Value(userKey{}).(string)
+	}, false)
+
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		user := "anonymous"
+		if cookie, err := r.Cookie("session"); err == nil {
+			user = cookie.Value
+		} else {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "bob"})
+		}
+
+		ctx := context.WithValue(r.Context(), userKey{}, user)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	}))
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL, WithCookies())
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	for _, expUser := range []string{"anonymous", "bob", "bob"} {
+		val, err, fault := client.RPCCall("whoami")
+		if err != nil || fault != nil {
+			t.Fatalf("whoami returned error %v, fault %v", err, fault)
+		} else if val != expUser {
+			t.Fatalf("whoami returned %v, not %s", val, expUser)
+		}
+	}
+}
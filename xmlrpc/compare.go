@@ -0,0 +1,255 @@
+package xmlrpc
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// return the canonical text of a scalar value
+func canonicalText(kind ValueKind, text string) string {
+	text2 := strings.TrimSpace(text)
+
+	switch kind {
+	case KindInt, KindI8:
+		if i, err := strconv.ParseInt(text2, 10, 64); err == nil {
+			return strconv.FormatInt(i, 10)
+		}
+	case KindBoolean:
+		switch strings.ToLower(text2) {
+		case "1", "true":
+			return "1"
+		case "0", "false":
+			return "0"
+		}
+	case KindDouble:
+		if f, err := strconv.ParseFloat(text2, 64); err == nil {
+			return strconv.FormatFloat(f, 'f', -1, 64)
+		}
+	case KindDateTime:
+		return text2
+	}
+
+	return text
+}
+
+// return a canonical copy of the value
+//
+// Scalars are rewritten in a single form (so "+01", "1" and " 1 " are all
+// the integer "1", and "1.50" and "1.5" are the same double) and struct
+// members are sorted by name.  The copy shares no data with the original.
+func (v *Value) Canonical() *Value {
+	if v == nil {
+		return nil
+	}
+
+	cv := &Value{Kind: v.Kind}
+	switch v.Kind {
+	case KindArray:
+		cv.Array = make([]*Value, len(v.Array))
+		for i, elem := range v.Array {
+			cv.Array[i] = elem.Canonical()
+		}
+	case KindStruct:
+		cv.Members = make([]*Member, len(v.Members))
+		for i, m := range v.Members {
+			cv.Members[i] = &Member{Name: m.Name, Value: m.Value.Canonical()}
+		}
+		sort.SliceStable(cv.Members, func(i, j int) bool {
+			return cv.Members[i].Name < cv.Members[j].Name
+		})
+	case KindBase64:
+		cv.Bytes = append([]byte{}, v.Bytes...)
+	case KindNil:
+	default:
+		cv.Text = canonicalText(v.Kind, v.Text)
+	}
+
+	return cv
+}
+
+// return a canonical copy of the document
+//
+// Writing canonical documents with a compact Encoder gives byte-for-byte
+// identical output for documents which are semantically equal.  Type tags
+// are already normalized when a document is parsed (<i4> becomes <int>
+// and an untyped value becomes a <string>).
+func (d *Document) Canonical() *Document {
+	cd := &Document{Response: d.Response, MethodName: d.MethodName,
+		Fault: d.Fault.Canonical()}
+	if d.Params != nil {
+		cd.Params = make([]*Value, len(d.Params))
+		for i, param := range d.Params {
+			cd.Params[i] = param.Canonical()
+		}
+	}
+
+	return cd
+}
+
+// describe a value in a Diff message
+func describeValue(v *Value) string {
+	if v == nil {
+		return "nothing"
+	}
+
+	switch v.Kind {
+	case KindNil:
+		return "nil"
+	case KindArray:
+		return fmt.Sprintf("array of %d values", len(v.Array))
+	case KindStruct:
+		return fmt.Sprintf("struct with %d members", len(v.Members))
+	case KindBase64:
+		return "base64 " + base64.StdEncoding.EncodeToString(v.Bytes)
+	case KindString:
+		return fmt.Sprintf("string %q", v.Text)
+	}
+
+	return v.Kind.String() + " " + v.Text
+}
+
+// find a member of a canonical struct
+func findMember(members []*Member, name string) *Value {
+	i := sort.Search(len(members), func(i int) bool {
+		return members[i].Name >= name
+	})
+	if i < len(members) && members[i].Name == name {
+		return members[i].Value
+	}
+
+	return nil
+}
+
+// return the first difference between two canonical values, or ""
+func diffValues(path string, a, b *Value) string {
+	if a == nil || b == nil {
+		if a == b {
+			return ""
+		}
+
+		return fmt.Sprintf("%s: %s != %s", path, describeValue(a),
+			describeValue(b))
+	} else if a.Kind != b.Kind {
+		return fmt.Sprintf("%s: %s != %s", path, describeValue(a),
+			describeValue(b))
+	}
+
+	switch a.Kind {
+	case KindArray:
+		n := len(a.Array)
+		if len(b.Array) < n {
+			n = len(b.Array)
+		}
+
+		for i := 0; i < n; i++ {
+			ipath := fmt.Sprintf("%s[%d]", path, i)
+			if diff := diffValues(ipath, a.Array[i], b.Array[i]); diff != "" {
+				return diff
+			}
+		}
+
+		if len(a.Array) != len(b.Array) {
+			return fmt.Sprintf("%s: %s != %s", path, describeValue(a),
+				describeValue(b))
+		}
+	case KindStruct:
+		for _, m := range a.Members {
+			bval := findMember(b.Members, m.Name)
+			if bval == nil {
+				return fmt.Sprintf("%s.%s: missing from second document",
+					path, m.Name)
+			}
+
+			mpath := path + "." + m.Name
+			if diff := diffValues(mpath, m.Value, bval); diff != "" {
+				return diff
+			}
+		}
+
+		for _, m := range b.Members {
+			if findMember(a.Members, m.Name) == nil {
+				return fmt.Sprintf("%s.%s: missing from first document",
+					path, m.Name)
+			}
+		}
+	case KindBase64:
+		if string(a.Bytes) != string(b.Bytes) {
+			return fmt.Sprintf("%s: %s != %s", path, describeValue(a),
+				describeValue(b))
+		}
+	default:
+		if a.Text != b.Text {
+			return fmt.Sprintf("%s: %s != %s", path, describeValue(a),
+				describeValue(b))
+		}
+	}
+
+	return ""
+}
+
+// return a description of the first difference between the documents,
+// or an empty string if they are semantically equal
+//
+// Values are compared in canonical form, so differences in whitespace,
+// <i4> and <int> tags, number formatting and struct member order are
+// ignored.  The description starts with the path to the differing value,
+// for example
+//
+//	params[0].items[2].price: double 1.5 != double 2
+func Diff(a, b *Document) string {
+	if a.Response != b.Response {
+		return fmt.Sprintf("document: %s != %s", documentType(a),
+			documentType(b))
+	} else if a.MethodName != b.MethodName {
+		return fmt.Sprintf("methodName: %q != %q", a.MethodName,
+			b.MethodName)
+	}
+
+	ca := a.Canonical()
+	cb := b.Canonical()
+
+	if ca.Fault != nil || cb.Fault != nil {
+		if ca.Fault == nil || cb.Fault == nil {
+			return fmt.Sprintf("fault: %s != %s", describeValue(ca.Fault),
+				describeValue(cb.Fault))
+		}
+
+		return diffValues("fault", ca.Fault, cb.Fault)
+	}
+
+	n := len(ca.Params)
+	if len(cb.Params) < n {
+		n = len(cb.Params)
+	}
+
+	for i := 0; i < n; i++ {
+		path := fmt.Sprintf("params[%d]", i)
+		if diff := diffValues(path, ca.Params[i], cb.Params[i]); diff != "" {
+			return diff
+		}
+	}
+
+	if len(ca.Params) != len(cb.Params) {
+		return fmt.Sprintf("params: %d values != %d values", len(ca.Params),
+			len(cb.Params))
+	}
+
+	return ""
+}
+
+// return true if the documents are semantically equal (see Diff)
+func Equal(a, b *Document) bool {
+	return Diff(a, b) == ""
+}
+
+// return the name of the document's top-level element
+func documentType(d *Document) string {
+	if d.Response {
+		return "methodResponse"
+	}
+
+	return "methodCall"
+}
@@ -0,0 +1,126 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func parseForDiff(t *testing.T, s string) *Document {
+	doc, err := ParseDocumentString(s)
+	if err != nil {
+		t.Fatalf("Cannot parse %s: %v", s, err)
+	}
+
+	return doc
+}
+
+func TestCanonical(t *testing.T) {
+	a := parseForDiff(t, `<methodResponse><params><param><value><struct>
+<member><name>b</name><value><double>1.50</double></value></member>
+<member><name>a</name><value><i4>+01</i4></value></member>
+</struct></value></param></params></methodResponse>`)
+	b := parseForDiff(t, `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><struct>
+        <member><name>a</name><value><int>1</int></value></member>
+        <member><name>b</name><value><double>1.5</double></value></member>
+      </struct></value>
+    </param>
+  </params>
+</methodResponse>`)
+
+	var abuf, bbuf bytes.Buffer
+	if err := NewEncoder(&abuf).Encode(a.Canonical()); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	} else if err = NewEncoder(&bbuf).Encode(b.Canonical()); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	if abuf.String() != bbuf.String() {
+		t.Fatalf("Canonical forms differ:\n%s\n%s", abuf.String(),
+			bbuf.String())
+	}
+
+	// the original must be unchanged
+	if a.Params[0].Members[0].Name != "b" ||
+		a.Params[0].Members[0].Value.Text != "1.50" {
+		t.Fatal("Canonical modified the original document")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	const base = `<methodCall><methodName>m</methodName><params>
+<param><value><array><data>
+<value><struct><member><name>price</name><value><double>1.5</double></value></member></struct></value>
+</data></array></value></param></params></methodCall>`
+
+	var tests = []struct {
+		other string
+		diff  string
+	}{
+		{base, ""},
+		{`<methodCall><methodName>m</methodName><params>
+<param><value><array><data>
+<value><struct><member><name>price</name><value><double>1.50</double></value></member></struct></value>
+</data></array></value></param></params></methodCall>`, ""},
+		{`<methodCall><methodName>m</methodName><params>
+<param><value><array><data>
+<value><struct><member><name>price</name><value><double>2</double></value></member></struct></value>
+</data></array></value></param></params></methodCall>`,
+			"params[0][0].price: double 1.5 != double 2"},
+		{`<methodCall><methodName>m</methodName><params>
+<param><value><array><data>
+<value><struct><member><name>price</name><value><int>2</int></value></member></struct></value>
+</data></array></value></param></params></methodCall>`,
+			"params[0][0].price: double 1.5 != int 2"},
+		{`<methodCall><methodName>m</methodName><params>
+<param><value><array><data>
+<value><struct><member><name>cost</name><value><double>1.5</double></value></member></struct></value>
+</data></array></value></param></params></methodCall>`,
+			"params[0][0].price: missing from second document"},
+		{`<methodCall><methodName>m</methodName><params>
+<param><value><array><data>
+<value><struct><member><name>price</name><value><double>1.5</double></value></member></struct></value>
+<value>x</value>
+</data></array></value></param></params></methodCall>`,
+			"params[0]: array of 1 values != array of 2 values"},
+		{`<methodCall><methodName>n</methodName><params></params></methodCall>`,
+			`methodName: "m" != "n"`},
+		{`<methodCall><methodName>m</methodName><params></params></methodCall>`,
+			"params: 1 values != 0 values"},
+		{`<methodResponse><params></params></methodResponse>`,
+			"document: methodCall != methodResponse"},
+	}
+
+	a := parseForDiff(t, base)
+	for i, test := range tests {
+		b := parseForDiff(t, test.other)
+		if diff := Diff(a, b); diff != test.diff {
+			t.Errorf("#%d: Diff returned %q, not %q", i, diff, test.diff)
+		} else if Equal(a, b) != (test.diff == "") {
+			t.Errorf("#%d: Equal disagrees with Diff", i)
+		}
+	}
+}
+
+func TestDiffFault(t *testing.T) {
+	a := parseForDiff(t, `<methodResponse><fault><value><struct>
+<member><name>faultString</name><value>Oops</value></member>
+<member><name>faultCode</name><value><i4>4</i4></value></member>
+</struct></value></fault></methodResponse>`)
+	b := parseForDiff(t, `<methodResponse><fault><value><struct>
+<member><name>faultCode</name><value><int>4</int></value></member>
+<member><name>faultString</name><value><string>Oops</string></value></member>
+</struct></value></fault></methodResponse>`)
+	c := parseForDiff(t, `<methodResponse><params><param><value>x</value>
+</param></params></methodResponse>`)
+
+	if diff := Diff(a, b); diff != "" {
+		t.Fatalf("Equal faults differ: %s", diff)
+	} else if diff = Diff(a, c); diff != "fault: struct with 2 members"+
+		" != nothing" {
+		t.Fatalf("Diff returned %q", diff)
+	}
+}
@@ -0,0 +1,269 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// default limit on the size of a request or response body after it has
+// been decompressed
+const DefaultMaxBodySize = 64 << 20
+
+// default size at which a Handler starts compressing responses
+const defaultCompressMin = 1024
+
+// ErrBodyTooLarge is returned when a request or response body is larger
+// than the configured limit, which protects against small compressed
+// bodies which expand to enormous documents
+var ErrBodyTooLarge = errors.New("Body exceeds size limit")
+
+// the encodings accepted by clients and handlers
+const acceptEncodings = "gzip, deflate"
+
+// a reader which fails with ErrBodyTooLarge after 'remaining' bytes
+type sizeLimitReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *sizeLimitReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+
+	// read one byte more than allowed to find out if the limit is hit
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n - 1, ErrBodyTooLarge
+	}
+
+	return n, err
+}
+
+// a decoded body which closes the original body
+type decodedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// return a reader for a "deflate" body, which should be zlib data but is
+// sent as raw deflate data by some implementations
+func deflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	hdr, err := br.Peek(2)
+	if err != nil && len(hdr) < 2 {
+		return flate.NewReader(br), nil
+	}
+
+	if hdr[0]&0x0f == 8 && (uint(hdr[0])<<8|uint(hdr[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+
+	return flate.NewReader(br), nil
+}
+
+// return the canonical name of a Content-Encoding, or "" if it is
+// not supported
+func normalizeEncoding(encoding string) string {
+	switch enc := strings.ToLower(strings.TrimSpace(encoding)); enc {
+	case "", "identity":
+		return "identity"
+	case "gzip", "x-gzip":
+		return "gzip"
+	case "deflate":
+		return enc
+	}
+
+	return ""
+}
+
+// return a reader which decodes a body sent with the Content-Encoding
+// and fails if the decoded body is longer than 'limit' bytes (if 'limit'
+// is positive)
+func decodeBody(body io.ReadCloser, encoding string,
+	limit int64) (io.ReadCloser, error) {
+	var r io.Reader = body
+
+	switch normalizeEncoding(encoding) {
+	case "identity":
+	case "gzip":
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	case "deflate":
+		zr, err := deflateReader(body)
+		if err != nil {
+			return nil, err
+		}
+		r = zr
+	default:
+		return nil, fmt.Errorf("Unsupported content encoding \"%s\"",
+			encoding)
+	}
+
+	if limit > 0 {
+		r = &sizeLimitReader{r: r, remaining: limit}
+	}
+
+	return decodedBody{Reader: r, Closer: body}, nil
+}
+
+// return the gzip-compressed form of 'data'
+func gzipBytes(data []byte) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	return buf.Bytes()
+}
+
+// choose a response encoding from an Accept-Encoding header, preferring
+// gzip, or return "" if the body should not be compressed
+func chooseEncoding(accept string) string {
+	var gzipOK, deflateOK bool
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		ok := true
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				ok = err == nil && q > 0
+			}
+		}
+
+		switch name {
+		case "gzip", "x-gzip":
+			gzipOK = ok
+		case "deflate":
+			deflateOK = ok
+		case "*":
+			gzipOK = gzipOK || ok
+		}
+	}
+
+	if gzipOK {
+		return "gzip"
+	} else if deflateOK {
+		return "deflate"
+	}
+
+	return ""
+}
+
+// a ResponseWriter which holds the response until it is complete, so it
+// can be compressed if it turns out to be large enough
+type bufferedResponse struct {
+	http.ResponseWriter
+	buf    bytes.Buffer
+	status int
+}
+
+func (br *bufferedResponse) Write(data []byte) (int, error) {
+	return br.buf.Write(data)
+}
+
+func (br *bufferedResponse) WriteHeader(status int) {
+	if br.status == 0 {
+		br.status = status
+	}
+}
+
+// send the response, compressed with 'encoding' if the body has at least
+// 'minSize' bytes
+func (br *bufferedResponse) finish(encoding string, minSize int) {
+	hdr := br.ResponseWriter.Header()
+	status := br.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if encoding == "" || minSize < 0 || br.buf.Len() < minSize ||
+		br.buf.Len() == 0 {
+		br.ResponseWriter.WriteHeader(status)
+		br.buf.WriteTo(br.ResponseWriter)
+		return
+	}
+
+	hdr.Set("Content-Encoding", encoding)
+	hdr.Add("Vary", "Accept-Encoding")
+	hdr.Del("Content-Length")
+	br.ResponseWriter.WriteHeader(status)
+
+	var zw io.WriteCloser
+	if encoding == "gzip" {
+		zw = gzip.NewWriter(br.ResponseWriter)
+	} else {
+		zw = zlib.NewWriter(br.ResponseWriter)
+	}
+	br.buf.WriteTo(zw)
+	zw.Close()
+}
+
+// compress responses of at least 'minSize' bytes (1024 by default) when
+// the client accepts gzip or deflate encoding; a negative size disables
+// compression
+func (h *Handler) SetCompression(minSize int) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.compressMin = minSize
+}
+
+// limit request bodies to 'size' bytes after decompression
+// (DefaultMaxBodySize by default); zero or a negative size removes the
+// limit
+func (h *Handler) SetMaxRequestSize(size int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.maxRequest = size
+}
+
+// return the handler's compression settings
+func (h *Handler) compressionSettings() (int, int64) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return h.compressMin, h.maxRequest
+}
+
+// ask the server for gzip or deflate compressed responses, and gzip
+// requests whose bodies have at least 'minSize' bytes (a negative size
+// never compresses requests, which is safest if the server may not
+// accept compressed requests)
+func WithCompression(minSize int) ClientOption {
+	return func(c *Client) error {
+		c.compress = true
+		c.compressMin = minSize
+		return nil
+	}
+}
+
+// limit response bodies to 'size' bytes after decompression
+// (DefaultMaxBodySize by default); zero or a negative size removes the
+// limit
+func WithMaxResponseSize(size int64) ClientOption {
+	return func(c *Client) error {
+		c.maxResponse = size
+		return nil
+	}
+}
@@ -0,0 +1,246 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newRepeatHandler(t *testing.T) *Handler {
+	h := NewHandler()
+	if err := h.RegisterFunc("repeat", func(s string, n int) string {
+		return strings.Repeat(s, n)
+	}, false); err != nil {
+		t.Fatalf("Cannot register repeat: %v", err)
+	}
+
+	return h
+}
+
+func TestClientCompression(t *testing.T) {
+	h := newRepeatHandler(t)
+	srvr, reqs := newRecordingServer(func(w http.ResponseWriter,
+		r *http.Request, n int) {
+		h.ServeHTTP(w, r)
+	})
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL, WithCompression(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		arg         string
+		count       int
+		reqEncoding string
+		encoding    string
+	}{
+		{"x", 10, "", ""},
+		{"abc", 5000, "", "gzip"},
+		{strings.Repeat("y", 3000), 1, "gzip", "gzip"},
+	}
+
+	for i, test := range tests {
+		val, err, fault := client.RPCCall("repeat", test.arg, test.count)
+		if err != nil || fault != nil {
+			t.Fatalf("#%d: call failed: %v, %v", i, err, fault)
+		} else if val != strings.Repeat(test.arg, test.count) {
+			t.Fatalf("#%d: returned %d bytes", i, len(val.(string)))
+		}
+
+		hdr := client.LastResponseHeader()
+		if enc := hdr.Get("Content-Encoding"); enc != test.encoding {
+			t.Errorf("#%d: response encoding %q, not %q", i, enc,
+				test.encoding)
+		}
+
+		if enc := reqs.last().header.Get(
+			"Content-Encoding"); enc != test.reqEncoding {
+			t.Errorf("#%d: request encoding %q, not %q", i, enc,
+				test.reqEncoding)
+		}
+	}
+}
+
+func TestHandlerCompressionDisabled(t *testing.T) {
+	h := newRepeatHandler(t)
+	h.SetCompression(-1)
+
+	srvr := httptest.NewServer(h)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL, WithCompression(-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err, fault := client.RPCCall("repeat", "abc", 5000); err != nil ||
+		fault != nil {
+		t.Fatalf("Call failed: %v, %v", err, fault)
+	} else if enc := client.LastResponseHeader().Get(
+		"Content-Encoding"); enc != "" {
+		t.Fatalf("Response was compressed with %s", enc)
+	}
+}
+
+// post a request body with the given encodings and return the response
+// and its decoded body
+func postEncoded(t *testing.T, url string, body []byte, encoding,
+	accept string) (*http.Response, string) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "text/xml")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	req.Header.Set("Accept-Encoding", accept)
+
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	rbody, err := decodeBody(resp.Body, resp.Header.Get("Content-Encoding"),
+		0)
+	if err != nil {
+		t.Fatalf("Cannot decode response: %v", err)
+	}
+
+	data, err := io.ReadAll(rbody)
+	if err != nil {
+		t.Fatalf("Cannot read response: %v", err)
+	}
+
+	return resp, string(data)
+}
+
+func TestHandlerDeflate(t *testing.T) {
+	srvr := httptest.NewServer(newRepeatHandler(t))
+	defer srvr.Close()
+
+	var buf bytes.Buffer
+	if err := marshalArray(&buf, "repeat", []interface{}{"abc",
+		1000}); err != nil {
+		t.Fatal(err)
+	}
+
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(buf.Bytes())
+	zw.Close()
+
+	// some implementations send raw deflate data instead of zlib data
+	var fbuf bytes.Buffer
+	fw, _ := flate.NewWriter(&fbuf, flate.DefaultCompression)
+	fw.Write(buf.Bytes())
+	fw.Close()
+
+	for _, body := range [][]byte{zbuf.Bytes(), fbuf.Bytes()} {
+		resp, data := postEncoded(t, srvr.URL, body, "deflate",
+			"deflate, gzip;q=0")
+		if enc := resp.Header.Get("Content-Encoding"); enc != "deflate" {
+			t.Errorf("Response encoding is %q", enc)
+		}
+
+		_, val, err, fault := UnmarshalString(data)
+		if err != nil || fault != nil {
+			t.Fatalf("Bad response %s: %v, %v", data, err, fault)
+		} else if val != strings.Repeat("abc", 1000) {
+			t.Fatalf("Returned %v", val)
+		}
+	}
+}
+
+func TestHandlerBadEncoding(t *testing.T) {
+	srvr := httptest.NewServer(newRepeatHandler(t))
+	defer srvr.Close()
+
+	resp, _ := postEncoded(t, srvr.URL, []byte("<methodCall/>"), "br", "")
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("Unknown encoding returned status %d", resp.StatusCode)
+	}
+
+	resp, _ = postEncoded(t, srvr.URL, []byte("not gzip"), "gzip", "")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Bad gzip data returned status %d", resp.StatusCode)
+	}
+}
+
+func TestDecompressionLimits(t *testing.T) {
+	h := newRepeatHandler(t)
+	h.SetMaxRequestSize(10000)
+
+	srvr := httptest.NewServer(h)
+	defer srvr.Close()
+
+	// a small compressed request which expands past the limit
+	var buf bytes.Buffer
+	if err := marshalArray(&buf, "repeat", []interface{}{
+		strings.Repeat("z", 100000), 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := gzipBytes(buf.Bytes())
+	if len(body) > 1000 {
+		t.Fatalf("Compressed body is %d bytes", len(body))
+	}
+
+	_, data := postEncoded(t, srvr.URL, body, "gzip", "")
+	if _, _, err, fault := UnmarshalString(data); err != nil {
+		t.Fatalf("Bad response %s: %v", data, err)
+	} else if fault == nil || !strings.Contains(fault.Msg,
+		ErrBodyTooLarge.Error()) {
+		t.Fatalf("Oversized request returned %v", fault)
+	}
+
+	// a response which expands past the client's limit
+	client, err := NewClientURL(srvr.URL, WithCompression(-1),
+		WithMaxResponseSize(5000))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err, _ := client.RPCCall("repeat", "x", 4000); err != nil {
+		t.Fatalf("Call under the limit failed: %v", err)
+	} else if _, err, _ = client.RPCCall("repeat", "x", 50000); err == nil {
+		t.Fatal("Oversized response was accepted")
+	} else if !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("Oversized response returned %v", err)
+	}
+}
+
+func TestChooseEncoding(t *testing.T) {
+	var tests = []struct {
+		accept   string
+		encoding string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", "gzip"},
+		{"deflate", "deflate"},
+		{"deflate, gzip", "gzip"},
+		{"gzip;q=0, deflate", "deflate"},
+		{"GZIP;q=0.5", "gzip"},
+		{"*", "gzip"},
+		{"br, x-gzip", "gzip"},
+		{"gzip;q=0", ""},
+	}
+
+	for _, test := range tests {
+		if enc := chooseEncoding(test.accept); enc != test.encoding {
+			t.Errorf("chooseEncoding(%q) returned %q, not %q", test.accept,
+				enc, test.encoding)
+		}
+	}
+}
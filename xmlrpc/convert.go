@@ -0,0 +1,346 @@
+package xmlrpc
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// layouts accepted when a string is converted to a time.Time
+var timeLayouts = []string{
+	ISO8601_LAYOUT,
+	"2006-01-02T15:04:05",
+	"20060102T150405",
+	time.RFC3339,
+}
+
+// report a value which cannot be converted to the requested type
+func convertError(path string, val interface{}, t reflect.Type) error {
+	if s, ok := val.(string); ok {
+		return fmt.Errorf("%s: cannot convert string \"%s\" to %v", path, s,
+			t)
+	}
+
+	return fmt.Errorf("%s: cannot convert %T (%v) to %v", path, val, val, t)
+}
+
+// return the XML-RPC member name for a struct field, taken from the
+// `xmlrpc:"name,omitempty"` tag if there is one, along with the tag's
+// "omitempty" flag
+//
+// 'ok' is false for unexported fields and fields tagged `xmlrpc:"-"`
+func fieldName(f reflect.StructField) (name string, omitEmpty bool,
+	ok bool) {
+	if f.PkgPath != "" {
+		return "", false, false
+	}
+
+	tag := f.Tag.Get("xmlrpc")
+	if tag == "-" {
+		return "", false, false
+	}
+
+	name = tag
+	if idx := strings.Index(tag, ","); idx >= 0 {
+		name = tag[:idx]
+		omitEmpty = strings.Contains(tag[idx:], ",omitempty")
+	}
+
+	if name == "" {
+		name = f.Name
+	}
+
+	return name, omitEmpty, true
+}
+
+// find the struct field for an XML-RPC member name, checking the
+// `xmlrpc:"name"` tag first, then the exact field name, then the field
+// name ignoring case
+func findField(t reflect.Type, name string) (reflect.StructField, bool) {
+	var folded *reflect.StructField
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		fname, _, ok := fieldName(f)
+		if !ok {
+			continue
+		} else if fname == name {
+			return f, true
+		} else if fname == f.Name && folded == nil &&
+			strings.EqualFold(fname, name) {
+			ff := f
+			folded = &ff
+		}
+	}
+
+	if folded != nil {
+		return *folded, true
+	}
+
+	return reflect.StructField{}, false
+}
+
+// Decode copies a value returned by Unmarshal or Client.RPCCall into the
+// Go variable 'ptr' points to, converting it as the server does for method
+// arguments (so an XML-RPC array can fill a []string, a struct can fill a
+// Go struct, etc.)
+func Decode(val interface{}, ptr interface{}) error {
+	pval := reflect.ValueOf(ptr)
+	if pval.Kind() != reflect.Ptr || pval.IsNil() {
+		return fmt.Errorf("Cannot decode into non-pointer %T", ptr)
+	}
+
+	rval, err := convertValue(val, pval.Type().Elem(), "value")
+	if err != nil {
+		return err
+	}
+
+	pval.Elem().Set(rval)
+	return nil
+}
+
+// convert a decoded XML-RPC value into a Go value of type 't'
+//
+// 'path' describes the location of the value (e.g. "params[1].items[2]")
+// and is used in any error message
+func convertValue(val interface{}, t reflect.Type,
+	path string) (reflect.Value, error) {
+	if val == nil {
+		return reflect.Zero(t), nil
+	}
+
+	vt := reflect.TypeOf(val)
+	if vt == t {
+		return reflect.ValueOf(val), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+		if vt.Implements(t) {
+			rv := reflect.New(t).Elem()
+			rv.Set(reflect.ValueOf(val))
+			return rv, nil
+		}
+	case reflect.Ptr:
+		elem, err := convertValue(val, t.Elem(), path)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(elem)
+		return ptr, nil
+	case reflect.Bool:
+		switch v := val.(type) {
+		case bool:
+			return reflect.ValueOf(v).Convert(t), nil
+		case int:
+			if v == 0 || v == 1 {
+				return reflect.ValueOf(v == 1).Convert(t), nil
+			}
+		case string:
+			if b, err := strconv.ParseBool(v); err == nil {
+				return reflect.ValueOf(b).Convert(t), nil
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		var i int64
+		var ok bool
+
+		switch v := val.(type) {
+		case int:
+			i, ok = int64(v), true
+		case int64:
+			i, ok = v, true
+		case float64:
+			i, ok = int64(v), float64(int64(v)) == v
+		case string:
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			i, ok = n, err == nil
+		}
+
+		if ok {
+			rv := reflect.New(t).Elem()
+			if !rv.OverflowInt(i) {
+				rv.SetInt(i)
+				return rv, nil
+			}
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		var u uint64
+		var ok bool
+
+		switch v := val.(type) {
+		case int:
+			u, ok = uint64(v), v >= 0
+		case int64:
+			u, ok = uint64(v), v >= 0
+		case float64:
+			u, ok = uint64(v), v >= 0 && float64(uint64(v)) == v
+		case string:
+			n, err := strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+			u, ok = n, err == nil
+		}
+
+		if ok {
+			rv := reflect.New(t).Elem()
+			if !rv.OverflowUint(u) {
+				rv.SetUint(u)
+				return rv, nil
+			}
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		var ok bool
+
+		switch v := val.(type) {
+		case int:
+			f, ok = float64(v), true
+		case int64:
+			f, ok = float64(v), true
+		case float64:
+			f, ok = v, true
+		case string:
+			n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			f, ok = n, err == nil
+		}
+
+		if ok {
+			rv := reflect.New(t).Elem()
+			rv.SetFloat(f)
+			return rv, nil
+		}
+	case reflect.String:
+		var s string
+		var ok = true
+
+		switch v := val.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		case float64:
+			s = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			s = strconv.FormatBool(v)
+		default:
+			ok = false
+		}
+
+		if ok {
+			return reflect.ValueOf(s).Convert(t), nil
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			switch v := val.(type) {
+			case []byte:
+				return reflect.ValueOf(append([]byte(nil), v...)).Convert(t),
+					nil
+			case string:
+				return reflect.ValueOf([]byte(v)).Convert(t), nil
+			}
+		}
+
+		rval := reflect.ValueOf(val)
+		if rval.Kind() == reflect.Slice || rval.Kind() == reflect.Array {
+			slice := reflect.MakeSlice(t, rval.Len(), rval.Len())
+			for i := 0; i < rval.Len(); i++ {
+				elem, err := convertValue(rval.Index(i).Interface(),
+					t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				slice.Index(i).Set(elem)
+			}
+
+			return slice, nil
+		}
+	case reflect.Array:
+		rval := reflect.ValueOf(val)
+		if rval.Kind() == reflect.Slice || rval.Kind() == reflect.Array {
+			if rval.Len() != t.Len() {
+				return reflect.Value{}, fmt.Errorf("%s: cannot convert"+
+					" %d-element array to %v", path, rval.Len(), t)
+			}
+
+			array := reflect.New(t).Elem()
+			for i := 0; i < rval.Len(); i++ {
+				elem, err := convertValue(rval.Index(i).Interface(),
+					t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				array.Index(i).Set(elem)
+			}
+
+			return array, nil
+		}
+	case reflect.Map:
+		smap, ok := val.(map[string]interface{})
+		if ok && t.Key().Kind() == reflect.String {
+			mval := reflect.MakeMap(t)
+			for key, v := range smap {
+				elem, err := convertValue(v, t.Elem(), path+"."+key)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				mval.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()),
+					elem)
+			}
+
+			return mval, nil
+		}
+	case reflect.Struct:
+		if t == timeType {
+			if s, ok := val.(string); ok {
+				for _, layout := range timeLayouts {
+					if tm, err := time.Parse(layout, s); err == nil {
+						return reflect.ValueOf(tm), nil
+					}
+				}
+			}
+
+			break
+		}
+
+		smap, ok := val.(map[string]interface{})
+		if ok {
+			sval := reflect.New(t).Elem()
+			for key, v := range smap {
+				f, found := findField(t, key)
+				if !found {
+					continue
+				}
+
+				fval, err := convertValue(v, f.Type, path+"."+key)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+
+				sval.FieldByIndex(f.Index).Set(fval)
+			}
+
+			return sval, nil
+		}
+	}
+
+	if vt.AssignableTo(t) {
+		rv := reflect.New(t).Elem()
+		rv.Set(reflect.ValueOf(val))
+		return rv, nil
+	}
+
+	return reflect.Value{}, convertError(path, val, t)
+}
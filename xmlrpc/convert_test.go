@@ -0,0 +1,140 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func checkConvert(t *testing.T, val interface{}, expVal interface{}) {
+	rval, err := convertValue(val, reflect.TypeOf(expVal), "val")
+	if err != nil {
+		t.Fatalf("Cannot convert %v to %T: %v", val, expVal, err)
+	}
+
+	if !reflect.DeepEqual(rval.Interface(), expVal) {
+		t.Fatalf("Converted %v to %v, not %v", val, rval.Interface(),
+			expVal)
+	}
+}
+
+func checkConvertError(t *testing.T, val interface{}, typ interface{},
+	expPath string) {
+	_, err := convertValue(val, reflect.TypeOf(typ), "val")
+	if err == nil {
+		t.Fatalf("Converted %v to %T", val, typ)
+	} else if !strings.HasPrefix(err.Error(), expPath+":") {
+		t.Fatalf("Error \"%v\" does not start with \"%s\"", err, expPath)
+	}
+}
+
+func TestConvertScalars(t *testing.T) {
+	checkConvert(t, 12, 12.0)
+	checkConvert(t, "12", 12)
+	checkConvert(t, "12", uint16(12))
+	checkConvert(t, 3.0, int64(3))
+	checkConvert(t, "2.5", float32(2.5))
+	checkConvert(t, 1, true)
+	checkConvert(t, "false", false)
+	checkConvert(t, 17, "17")
+	checkConvert(t, []byte("abc"), "abc")
+	checkConvert(t, "abc", []byte("abc"))
+	checkConvert(t, nil, 0)
+
+	checkConvertError(t, "abc", 0, "val")
+	checkConvertError(t, 2.5, 0, "val")
+	checkConvertError(t, 300, int8(0), "val")
+	checkConvertError(t, -1, uint(0), "val")
+	checkConvertError(t, 2, false, "val")
+}
+
+func TestConvertTime(t *testing.T) {
+	expVal := time.Date(1998, 7, 17, 14, 8, 55, 0, time.UTC)
+
+	checkConvert(t, expVal, expVal)
+	checkConvert(t, "19980717T14:08:55", expVal)
+	checkConvert(t, "1998-07-17T14:08:55Z", expVal)
+
+	checkConvertError(t, "yesterday", expVal, "val")
+}
+
+type convertInner struct {
+	Name  string
+	Count int `xmlrpc:"n"`
+}
+
+type convertOuter struct {
+	Inner   convertInner
+	Ptr     *convertInner
+	List    []convertInner
+	Skipped string `xmlrpc:"-"`
+	private int
+}
+
+func TestConvertStruct(t *testing.T) {
+	val := map[string]interface{}{
+		"inner":   map[string]interface{}{"name": "a", "n": 1},
+		"Ptr":     map[string]interface{}{"Name": "b", "n": "2"},
+		"List":    []interface{}{map[string]interface{}{"n": 3}},
+		"Skipped": "x",
+		"private": 9,
+		"unknown": true,
+	}
+
+	expVal := convertOuter{
+		Inner: convertInner{Name: "a", Count: 1},
+		Ptr:   &convertInner{Name: "b", Count: 2},
+		List:  []convertInner{{Count: 3}},
+	}
+
+	checkConvert(t, val, expVal)
+
+	bad := map[string]interface{}{
+		"List": []interface{}{
+			map[string]interface{}{"n": 3},
+			map[string]interface{}{"n": "many"},
+		},
+	}
+	checkConvertError(t, bad, expVal, "val.List[1].n")
+}
+
+func TestConvertContainers(t *testing.T) {
+	checkConvert(t, []interface{}{"a", "b"}, []string{"a", "b"})
+	checkConvert(t, []interface{}{1, "2"}, [2]int{1, 2})
+	checkConvert(t, map[string]interface{}{"a": 1, "b": 2.5},
+		map[string]float64{"a": 1, "b": 2.5})
+	checkConvert(t, []interface{}{1, "x"}, []interface{}{1, "x"})
+
+	checkConvertError(t, []interface{}{1, 2, 3}, [2]int{}, "val")
+	checkConvertError(t, map[string]interface{}{"a": "b"}, map[string]int{},
+		"val.a")
+	checkConvertError(t, "abc", []int{}, "val")
+}
+
+func TestDecode(t *testing.T) {
+	var words []string
+	if err := Decode([]interface{}{"a", 2}, &words); err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	} else if !reflect.DeepEqual(words, []string{"a", "2"}) {
+		t.Fatalf("Decoded %v", words)
+	}
+
+	var inner convertInner
+	err := Decode(map[string]interface{}{"name": "x", "n": 3}, &inner)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	} else if inner.Name != "x" || inner.Count != 3 {
+		t.Fatalf("Decoded %+v", inner)
+	}
+
+	if err = Decode("abc", &inner); err == nil {
+		t.Fatal("Decoded a string into a struct")
+	} else if !strings.HasPrefix(err.Error(), "value:") {
+		t.Fatalf("Unexpected error \"%v\"", err)
+	}
+
+	if err = Decode(1, inner); err == nil {
+		t.Fatal("Decoded into a non-pointer")
+	}
+}
@@ -0,0 +1,447 @@
+package xmlrpc
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ValueKind is the XML-RPC type of a document Value
+type ValueKind int
+
+// XML-RPC value types
+const (
+	KindString ValueKind = iota
+	KindInt
+	KindI8
+	KindBoolean
+	KindDouble
+	KindDateTime
+	KindBase64
+	KindNil
+	KindArray
+	KindStruct
+)
+
+// XML-RPC tag names for each ValueKind
+var kindTags = []string{
+	KindString:   "string",
+	KindInt:      "int",
+	KindI8:       "i8",
+	KindBoolean:  "boolean",
+	KindDouble:   "double",
+	KindDateTime: "dateTime.iso8601",
+	KindBase64:   "base64",
+	KindNil:      "nil",
+	KindArray:    "array",
+	KindStruct:   "struct",
+}
+
+// return the XML-RPC tag name for the kind
+func (k ValueKind) String() string {
+	if k < 0 || int(k) >= len(kindTags) {
+		return fmt.Sprintf("ValueKind#%d", int(k))
+	}
+
+	return kindTags[k]
+}
+
+// A Value is a single XML-RPC value which remembers its XML-RPC type, so
+// it can be written back out without losing information (unlike the
+// values returned by Unmarshal, where an <i8> and an <int> or a <string>
+// and a <dateTime.iso8601> can be indistinguishable)
+type Value struct {
+	Kind ValueKind
+
+	// the text of a string, int, i8, boolean ("0" or "1"), double or
+	// dateTime value
+	Text string
+
+	// the decoded data of a base64 value
+	Bytes []byte
+
+	// the elements of an array
+	Array []*Value
+
+	// the members of a struct, in document order
+	Members []*Member
+}
+
+// A Member is a single named member of an XML-RPC struct
+type Member struct {
+	Name  string
+	Value *Value
+}
+
+// A Document is a parsed <methodCall> or <methodResponse> whose values
+// keep their XML-RPC types
+type Document struct {
+	// true for a <methodResponse>, false for a <methodCall>
+	Response bool
+
+	// name of the procedure called by a <methodCall>
+	MethodName string
+
+	// request or response parameters
+	Params []*Value
+
+	// fault value (usually a struct holding "faultCode" and
+	// "faultString") if the document is a fault response
+	Fault *Value
+}
+
+// document parser built on the XML-RPC tokenizer
+type docParser struct {
+	p *xml.Decoder
+}
+
+// return the next tag, skipping processing instructions and whitespace
+func (dp *docParser) nextTag() (*xmlToken, error) {
+	for {
+		tok, err := getNextToken(dp.p)
+		if err != nil {
+			return nil, err
+		} else if tok == nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		if tok.IsNone() {
+			continue
+		} else if tok.IsText() {
+			if strings.TrimSpace(tok.Text()) != "" {
+				return nil, fmt.Errorf("Unexpected text \"%s\"", tok.Text())
+			}
+
+			continue
+		}
+
+		return tok, nil
+	}
+}
+
+// read the next tag, which must be a start or end tag for 'token'
+func (dp *docParser) expect(token int, isStart bool) error {
+	tok, err := dp.nextTag()
+	if err != nil {
+		return err
+	} else if !tok.Is(token) || tok.isStart != isStart {
+		slash := "/"
+		if isStart {
+			slash = ""
+		}
+
+		return fmt.Errorf("Expected <%s%s>, not %s", slash,
+			getTokenName(token), tok)
+	}
+
+	return nil
+}
+
+// read the text up to the end tag for 'token'
+func (dp *docParser) text(token int) (string, error) {
+	var buf strings.Builder
+	for {
+		tok, err := getNextToken(dp.p)
+		if err != nil {
+			return "", err
+		} else if tok == nil {
+			return "", io.ErrUnexpectedEOF
+		}
+
+		if tok.IsText() {
+			buf.WriteString(tok.Text())
+		} else if tok.Is(token) && !tok.isStart {
+			return buf.String(), nil
+		} else if !tok.IsNone() {
+			return "", fmt.Errorf("Unexpected %s in <%s>", tok,
+				getTokenName(token))
+		}
+	}
+}
+
+// parse the rest of a <value> element whose start tag has been read
+func (dp *docParser) value() (*Value, error) {
+	var raw strings.Builder
+	for {
+		tok, err := getNextToken(dp.p)
+		if err != nil {
+			return nil, err
+		} else if tok == nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		if tok.IsNone() {
+			continue
+		} else if tok.IsText() {
+			raw.WriteString(tok.Text())
+			continue
+		} else if tok.Is(tokenValue) && !tok.isStart {
+			// a value without a type tag is a string
+			return &Value{Kind: KindString, Text: raw.String()}, nil
+		} else if !tok.IsDataType() || !tok.isStart {
+			return nil, fmt.Errorf("Unexpected %s in <value>", tok)
+		}
+
+		if strings.TrimSpace(raw.String()) != "" {
+			return nil, fmt.Errorf("Unexpected text \"%s\" before <%s>",
+				raw.String(), tok.Name())
+		}
+
+		val, err := dp.typedValue(tok.token)
+		if err != nil {
+			return nil, err
+		}
+
+		return val, dp.expect(tokenValue, false)
+	}
+}
+
+// parse a typed value whose start tag has been read
+func (dp *docParser) typedValue(token int) (*Value, error) {
+	switch token {
+	case tokenArray:
+		return dp.array()
+	case tokenStruct:
+		return dp.structValue()
+	case tokenNil:
+		return &Value{Kind: KindNil}, dp.expect(tokenNil, false)
+	}
+
+	text, err := dp.text(token)
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case tokenString:
+		return &Value{Kind: KindString, Text: text}, nil
+	case tokenInt:
+		i, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad <int> value \"%s\"", text)
+		}
+
+		return &Value{Kind: KindInt, Text: strconv.FormatInt(i, 10)}, nil
+	case tokenI8:
+		i, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Bad <i8> value \"%s\"", text)
+		}
+
+		return &Value{Kind: KindI8, Text: strconv.FormatInt(i, 10)}, nil
+	case tokenBoolean:
+		text = strings.TrimSpace(text)
+		if text != "0" && text != "1" {
+			return nil, fmt.Errorf("Bad <boolean> value \"%s\"", text)
+		}
+
+		return &Value{Kind: KindBoolean, Text: text}, nil
+	case tokenDouble:
+		text = strings.TrimSpace(text)
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return nil, fmt.Errorf("Bad <double> value \"%s\"", text)
+		}
+
+		return &Value{Kind: KindDouble, Text: text}, nil
+	case tokenDateTime:
+		return &Value{Kind: KindDateTime, Text: strings.TrimSpace(text)},
+			nil
+	case tokenBase64:
+		data, err := decodeBase64(text)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Value{Kind: KindBase64, Bytes: data}, nil
+	}
+
+	return nil, fmt.Errorf("Unknown type <%s>", getTokenName(token))
+}
+
+// parse the rest of an <array>
+func (dp *docParser) array() (*Value, error) {
+	if err := dp.expect(tokenData, true); err != nil {
+		return nil, err
+	}
+
+	val := &Value{Kind: KindArray, Array: []*Value{}}
+	for {
+		tok, err := dp.nextTag()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Is(tokenData) && !tok.isStart {
+			break
+		} else if !tok.Is(tokenValue) || !tok.isStart {
+			return nil, fmt.Errorf("Unexpected %s in <data>", tok)
+		}
+
+		elem, err := dp.value()
+		if err != nil {
+			return nil, err
+		}
+
+		val.Array = append(val.Array, elem)
+	}
+
+	return val, dp.expect(tokenArray, false)
+}
+
+// parse the rest of a <struct>
+func (dp *docParser) structValue() (*Value, error) {
+	val := &Value{Kind: KindStruct, Members: []*Member{}}
+	for {
+		tok, err := dp.nextTag()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Is(tokenStruct) && !tok.isStart {
+			return val, nil
+		} else if !tok.Is(tokenMember) || !tok.isStart {
+			return nil, fmt.Errorf("Unexpected %s in <struct>", tok)
+		}
+
+		if err = dp.expect(tokenName, true); err != nil {
+			return nil, err
+		}
+
+		name, err := dp.text(tokenName)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = dp.expect(tokenValue, true); err != nil {
+			return nil, err
+		}
+
+		mval, err := dp.value()
+		if err != nil {
+			return nil, err
+		}
+
+		val.Members = append(val.Members, &Member{Name: name, Value: mval})
+
+		if err = dp.expect(tokenMember, false); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parse the rest of a <params> element
+func (dp *docParser) params() ([]*Value, error) {
+	params := []*Value{}
+	for {
+		tok, err := dp.nextTag()
+		if err != nil {
+			return nil, err
+		}
+
+		if tok.Is(tokenParams) && !tok.isStart {
+			return params, nil
+		} else if !tok.Is(tokenParam) || !tok.isStart {
+			return nil, fmt.Errorf("Unexpected %s in <params>", tok)
+		}
+
+		if err = dp.expect(tokenValue, true); err != nil {
+			return nil, err
+		}
+
+		val, err := dp.value()
+		if err != nil {
+			return nil, err
+		}
+
+		params = append(params, val)
+
+		if err = dp.expect(tokenParam, false); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// parse an XML-RPC <methodCall> or <methodResponse> into a Document
+func ParseDocument(r io.Reader) (*Document, error) {
+	p, err := newXMLDecoder(r, "")
+	if err != nil {
+		return nil, err
+	}
+	dp := &docParser{p: p}
+
+	tok, err := dp.nextTag()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &Document{}
+	switch {
+	case tok.Is(tokenMethodCall) && tok.isStart:
+		if err = dp.expect(tokenMethodName, true); err != nil {
+			return nil, err
+		}
+
+		name, err := dp.text(tokenMethodName)
+		if err != nil {
+			return nil, err
+		}
+		doc.MethodName = strings.TrimSpace(name)
+	case tok.Is(tokenMethodResponse) && tok.isStart:
+		doc.Response = true
+	default:
+		return nil, fmt.Errorf("Unexpected %s at start of document", tok)
+	}
+
+	endToken := tok.token
+	for {
+		if tok, err = dp.nextTag(); err != nil {
+			return nil, err
+		}
+
+		if tok.Is(endToken) && !tok.isStart {
+			break
+		} else if tok.Is(tokenParams) && tok.isStart &&
+			doc.Params == nil && doc.Fault == nil {
+			if doc.Params, err = dp.params(); err != nil {
+				return nil, err
+			}
+		} else if tok.Is(tokenFault) && tok.isStart && doc.Response &&
+			doc.Params == nil && doc.Fault == nil {
+			if err = dp.expect(tokenValue, true); err != nil {
+				return nil, err
+			}
+
+			if doc.Fault, err = dp.value(); err != nil {
+				return nil, err
+			}
+
+			if err = dp.expect(tokenFault, false); err != nil {
+				return nil, err
+			}
+		} else {
+			return nil, fmt.Errorf("Unexpected %s in <%s>", tok,
+				getTokenName(endToken))
+		}
+	}
+
+	if doc.Response && doc.Params == nil && doc.Fault == nil {
+		return nil, errors.New("Response has neither <params> nor <fault>")
+	}
+
+	return doc, nil
+}
+
+// parse an XML-RPC document held in a string
+func ParseDocumentString(s string) (*Document, error) {
+	return ParseDocument(strings.NewReader(s))
+}
+
+// write the document as indented XML
+func (d *Document) WriteXML(w io.Writer) error {
+	e := NewEncoder(w)
+	e.SetIndent("  ")
+	return e.Encode(d)
+}
@@ -0,0 +1,227 @@
+package xmlrpc
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// An Encoder writes XML-RPC documents to an output stream, either compact
+// (with no whitespace between tags) or indented with one element per
+// line.  Every value is written with an explicit type tag, so indenting
+// never changes the value of an untyped string.
+type Encoder struct {
+	w      io.Writer
+	indent string
+	err    error
+
+	// output character set (nil for UTF-8) and whether to name it in the
+	// XML declaration
+	charset     *charset
+	declareUTF8 bool
+
+	// destination for the current document (w, or a writer converting
+	// to the character set)
+	out io.Writer
+}
+
+// create an encoder which writes compact documents to 'w'
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// indent each nested element with another copy of 'indent'; an empty
+// string switches back to compact output
+func (e *Encoder) SetIndent(indent string) {
+	e.indent = indent
+}
+
+// write documents in the named character set ("UTF-8", "ISO-8859-1",
+// "windows-1252" or "US-ASCII") and name it in the XML declaration;
+// characters outside the set are written as XML character references
+func (e *Encoder) SetEncoding(charset string) error {
+	cs, err := lookupCharset(charset)
+	if err != nil {
+		return err
+	}
+
+	e.charset = cs
+	e.declareUTF8 = cs == nil && charset != ""
+	return nil
+}
+
+// write a string unless an earlier write failed
+func (e *Encoder) write(s string) {
+	if e.err == nil {
+		_, e.err = io.WriteString(e.out, s)
+	}
+}
+
+// write escaped text unless an earlier write failed
+func (e *Encoder) escape(s string) {
+	if e.err == nil {
+		e.err = xml.EscapeText(e.out, []byte(s))
+	}
+}
+
+// start a new line at the given depth (in indented mode)
+func (e *Encoder) newline(depth int) {
+	if e.indent != "" {
+		e.write("\n" + strings.Repeat(e.indent, depth))
+	}
+}
+
+// write a <value> element at the given depth
+func (e *Encoder) value(v *Value, depth int) {
+	if v == nil {
+		if e.err == nil {
+			e.err = errors.New("Nil value")
+		}
+		return
+	}
+
+	e.newline(depth)
+	e.write("<value>")
+
+	switch v.Kind {
+	case KindNil:
+		e.write("<nil/>")
+	case KindArray:
+		if len(v.Array) == 0 {
+			e.write("<array><data></data></array>")
+			break
+		}
+
+		e.newline(depth + 1)
+		e.write("<array>")
+		e.newline(depth + 2)
+		e.write("<data>")
+		for _, elem := range v.Array {
+			e.value(elem, depth+3)
+		}
+		e.newline(depth + 2)
+		e.write("</data>")
+		e.newline(depth + 1)
+		e.write("</array>")
+		e.newline(depth)
+	case KindStruct:
+		if len(v.Members) == 0 {
+			e.write("<struct></struct>")
+			break
+		}
+
+		e.newline(depth + 1)
+		e.write("<struct>")
+		for _, m := range v.Members {
+			e.newline(depth + 2)
+			e.write("<member>")
+			e.newline(depth + 3)
+			e.write("<name>")
+			e.escape(m.Name)
+			e.write("</name>")
+			e.value(m.Value, depth+3)
+			e.newline(depth + 2)
+			e.write("</member>")
+		}
+		e.newline(depth + 1)
+		e.write("</struct>")
+		e.newline(depth)
+	case KindBase64:
+		e.write("<base64>")
+		e.write(base64.StdEncoding.EncodeToString(v.Bytes))
+		e.write("</base64>")
+	case KindString, KindInt, KindI8, KindBoolean, KindDouble,
+		KindDateTime:
+		tag := v.Kind.String()
+		e.write("<" + tag + ">")
+		e.escape(v.Text)
+		e.write("</" + tag + ">")
+	default:
+		if e.err == nil {
+			e.err = fmt.Errorf("Unknown value kind %v", v.Kind)
+		}
+	}
+
+	e.write("</value>")
+}
+
+// write the document, followed by a newline
+func (e *Encoder) Encode(d *Document) error {
+	e.err = nil
+	e.out = e.w
+	if e.charset != nil {
+		e.out = &charsetWriter{w: e.w, cs: e.charset}
+	}
+
+	name := "methodCall"
+	if d.Response {
+		name = "methodResponse"
+	}
+
+	if e.declareUTF8 {
+		e.write("<?xml version=\"1.0\" encoding=\"UTF-8\"?>")
+	} else {
+		e.write(xmlDeclaration(e.charset))
+	}
+	e.newline(0)
+	e.write("<" + name + ">")
+
+	if !d.Response {
+		e.newline(1)
+		e.write("<methodName>")
+		e.escape(d.MethodName)
+		e.write("</methodName>")
+	}
+
+	if d.Fault != nil {
+		e.newline(1)
+		e.write("<fault>")
+		e.value(d.Fault, 2)
+		e.newline(1)
+		e.write("</fault>")
+	} else {
+		e.newline(1)
+		e.write("<params>")
+		for _, param := range d.Params {
+			e.newline(2)
+			e.write("<param>")
+			e.value(param, 3)
+			e.newline(2)
+			e.write("</param>")
+		}
+		e.newline(1)
+		e.write("</params>")
+	}
+
+	e.newline(0)
+	e.write("</" + name + ">\n")
+
+	return e.err
+}
+
+// build a Document from the Go values and write it
+func (e *Encoder) encodeValues(methodName string, args []interface{}) error {
+	doc, err := makeDocument(methodName, args)
+	if err != nil {
+		return err
+	}
+
+	return e.Encode(doc)
+}
+
+// write a <methodCall> for 'methodName' with the arguments
+func (e *Encoder) EncodeCall(methodName string, args ...interface{}) error {
+	if methodName == "" {
+		return errors.New("No method name")
+	}
+
+	return e.encodeValues(methodName, args)
+}
+
+// write a <methodResponse> holding the values
+func (e *Encoder) EncodeResponse(args ...interface{}) error {
+	return e.encodeValues("", args)
+}
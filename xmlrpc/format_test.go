@@ -0,0 +1,148 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const formatCallXML = `<?xml version="1.0"?>
+<methodCall>
+	<methodName>a.b</methodName>
+<params><param><value><i4> 7 </i4></value></param>
+<param><value>text &amp; more</value></param>
+<param><value><array><data>
+  <value><nil/></value>
+  <value><struct>
+    <member><name>x</name><value><boolean>1</boolean></value></member>
+  </struct></value>
+  <value><array><data></data></array></value>
+</data></array></value></param>
+</params></methodCall>`
+
+func TestEncodeCompact(t *testing.T) {
+	doc, err := ParseDocumentString(formatCallXML)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = NewEncoder(&buf).Encode(doc); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	expected := `<?xml version="1.0"?><methodCall>` +
+		`<methodName>a.b</methodName><params>` +
+		`<param><value><int>7</int></value></param>` +
+		`<param><value><string>text &amp; more</string></value></param>` +
+		`<param><value><array><data><value><nil/></value>` +
+		`<value><struct><member><name>x</name>` +
+		`<value><boolean>1</boolean></value></member></struct></value>` +
+		`<value><array><data></data></array></value>` +
+		`</data></array></value></param>` +
+		"</params></methodCall>\n"
+	if buf.String() != expected {
+		t.Fatalf("Encode returned\n%s\nnot\n%s", buf.String(), expected)
+	}
+}
+
+func TestEncodeIndent(t *testing.T) {
+	doc, err := ParseDocumentString(formatCallXML)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetIndent("\t")
+	if err = e.Encode(doc); err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	expected := `<?xml version="1.0"?>
+<methodCall>
+	<methodName>a.b</methodName>
+	<params>
+		<param>
+			<value><int>7</int></value>
+		</param>
+		<param>
+			<value><string>text &amp; more</string></value>
+		</param>
+		<param>
+			<value>
+				<array>
+					<data>
+						<value><nil/></value>
+						<value>
+							<struct>
+								<member>
+									<name>x</name>
+									<value><boolean>1</boolean></value>
+								</member>
+							</struct>
+						</value>
+						<value><array><data></data></array></value>
+					</data>
+				</array>
+			</value>
+		</param>
+	</params>
+</methodCall>
+`
+	if buf.String() != expected {
+		t.Fatalf("Encode returned\n%s\nnot\n%s", buf.String(), expected)
+	}
+
+	// indenting must not change any values
+	idoc, err := ParseDocument(&buf)
+	if err != nil {
+		t.Fatalf("Cannot parse indented XML: %v", err)
+	} else if diff := Diff(doc, idoc); diff != "" {
+		t.Fatalf("Indented document differs: %s", diff)
+	}
+}
+
+func TestEncodeCall(t *testing.T) {
+	args := []interface{}{12, "a <b>", []interface{}{true, 2.5},
+		map[string]interface{}{"k": "v"}}
+
+	var buf bytes.Buffer
+	e := NewEncoder(&buf)
+	e.SetIndent("  ")
+	if err := e.EncodeCall("pkg.method", args...); err != nil {
+		t.Fatalf("EncodeCall failed: %v", err)
+	}
+
+	methodName, params, err, fault := unmarshalParams(&buf)
+	if err != nil || fault != nil {
+		t.Fatalf("Unmarshal failed: %v, %v", err, fault)
+	} else if methodName != "pkg.method" {
+		t.Fatalf("Method name is \"%s\"", methodName)
+	} else if !reflect.DeepEqual(params, args) {
+		t.Fatalf("Got %v, not %v", params, args)
+	}
+
+	if err = e.EncodeCall(""); err == nil {
+		t.Fatal("EncodeCall accepted an empty method name")
+	}
+
+	buf.Reset()
+	if err = NewEncoder(&buf).EncodeResponse("ok"); err != nil {
+		t.Fatalf("EncodeResponse failed: %v", err)
+	} else if !strings.Contains(buf.String(),
+		"<methodResponse><params><param><value><string>ok<") {
+		t.Fatalf("EncodeResponse returned %s", buf.String())
+	}
+}
+
+func TestEncodeBadValue(t *testing.T) {
+	doc := &Document{Response: true,
+		Params: []*Value{{Kind: ValueKind(99)}}}
+
+	var buf bytes.Buffer
+	if err := NewEncoder(&buf).Encode(doc); err == nil {
+		t.Fatal("Encode accepted an unknown value kind")
+	}
+}
@@ -0,0 +1,173 @@
+package xmlrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// default path prefix for gateway requests
+const defaultGatewayPrefix = "/rpc/"
+
+// A Gateway is an http.Handler which lets clients which only speak JSON
+// call XML-RPC procedures.  A request like
+//
+//	POST /rpc/blog.getPost
+//	Content-Type: application/json
+//
+//	[1234, {"full": true}]
+//
+// calls "blog.getPost" with the elements of the JSON array as parameters
+// (a JSON object is sent as a single struct parameter) and returns the
+// result as JSON.  A fault is returned as
+//
+//	{"error": {"code": -32601, "message": "Unknown method ..."}}
+//
+// with an HTTP status of 404 for an unknown method, 400 for bad
+// parameters and 500 for any other fault.  If the XML-RPC server can't be
+// reached the status is 502 (or 504 if the request timed out).
+type Gateway struct {
+	// path prefix which precedes the method name ("/rpc/" by default)
+	Prefix string
+
+	call ClientInvoker
+}
+
+// create a gateway which sends requests to an XML-RPC server using the
+// Client
+func NewGateway(c *Client) *Gateway {
+	return &Gateway{Prefix: defaultGatewayPrefix, call: c.call}
+}
+
+// create a gateway which calls the procedures registered with the local
+// Handler (after running any interceptors) without making an XML-RPC
+// request
+func NewLocalGateway(h *Handler) *Gateway {
+	return &Gateway{Prefix: defaultGatewayPrefix,
+		call: func(ctx context.Context, methodName string,
+			args []interface{}) (interface{}, error, *Fault) {
+			results, fault := h.dispatch(ctx, methodName, args)
+			if fault != nil {
+				return nil, nil, fault
+			}
+
+			return extractParams(results), nil, nil
+		}}
+}
+
+// the JSON error object sent for a fault or failed request
+type gatewayError struct {
+	Error jsonError `json:"error"`
+}
+
+// send a JSON error response
+func writeGatewayError(resp http.ResponseWriter, status int, code int,
+	msg string) {
+	resp.Header().Set("Content-Type", "application/json")
+	resp.WriteHeader(status)
+	json.NewEncoder(resp).Encode(&gatewayError{
+		Error: jsonError{Code: code, Message: msg}})
+}
+
+// return the HTTP status used for a fault
+func faultStatus(fault *Fault) int {
+	switch fault.Code {
+	case errUnknownMethod:
+		return http.StatusNotFound
+	case errInvalidParams, errInvalidRequest, errNotWellFormed:
+		return http.StatusBadRequest
+	}
+
+	return http.StatusInternalServerError
+}
+
+// read the request body, returning the parameters for the call
+func gatewayParams(req *http.Request) ([]interface{}, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	} else if len(strings.TrimSpace(string(body))) == 0 {
+		return nil, nil
+	}
+
+	val, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := val.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		return []interface{}{v}, nil
+	}
+
+	return nil, errors.New("Body must be a JSON array or object")
+}
+
+// handle a JSON request
+func (g *Gateway) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" {
+		resp.Header().Set("Allow", "POST")
+		writeGatewayError(resp, http.StatusMethodNotAllowed,
+			errInvalidRequest,
+			fmt.Sprintf("Method %s not allowed", req.Method))
+		return
+	}
+
+	prefix := g.Prefix
+	if prefix == "" {
+		prefix = defaultGatewayPrefix
+	}
+
+	if !strings.HasPrefix(req.URL.Path, prefix) ||
+		len(req.URL.Path) == len(prefix) {
+		writeGatewayError(resp, http.StatusNotFound, errUnknownMethod,
+			fmt.Sprintf("No method name in \"%s\"", req.URL.Path))
+		return
+	}
+	methodName := req.URL.Path[len(prefix):]
+
+	ctype := req.Header.Get("Content-Type")
+	if ctype != "" && !isJSONContentType(ctype) {
+		writeGatewayError(resp, http.StatusUnsupportedMediaType,
+			errInvalidRequest,
+			fmt.Sprintf("Unsupported content type \"%s\"", ctype))
+		return
+	}
+
+	params, err := gatewayParams(req)
+	if err != nil {
+		writeGatewayError(resp, http.StatusBadRequest, errNotWellFormed,
+			fmt.Sprintf("Bad request body: %v", err))
+		return
+	}
+
+	result, err, fault := g.call(req.Context(), methodName, params)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, context.DeadlineExceeded) {
+			status = http.StatusGatewayTimeout
+		}
+
+		writeGatewayError(resp, status, errInternal, err.Error())
+		return
+	} else if fault != nil {
+		writeGatewayError(resp, faultStatus(fault), fault.Code, fault.Msg)
+		return
+	}
+
+	data, err := marshalJSONValue(result)
+	if err != nil {
+		writeGatewayError(resp, http.StatusInternalServerError, errInternal,
+			fmt.Sprintf("Failed to marshal %s: %v", methodName, err))
+		return
+	}
+
+	resp.Header().Set("Content-Type", "application/json")
+	resp.Write(append(data, '\n'))
+}
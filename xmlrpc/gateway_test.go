@@ -0,0 +1,140 @@
+package xmlrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// send a request to the gateway and decode the JSON response
+func postGateway(t *testing.T, g http.Handler, method string, path string,
+	body string) (int, interface{}) {
+	req, err := http.NewRequest(method, path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	g.ServeHTTP(rec, req)
+
+	ctype := rec.Header().Get("Content-Type")
+	if ctype != "application/json" {
+		t.Fatalf("%s %s returned Content-Type \"%s\"", method, path, ctype)
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Cannot decode response %s: %v", rec.Body.String(), err)
+	}
+
+	return rec.Code, v
+}
+
+// check that a gateway response is an error with the expected status
+func checkGatewayError(t *testing.T, status int, v interface{},
+	expStatus int, expCode int) {
+	if status != expStatus {
+		t.Errorf("Status is %d, not %d (%v)", status, expStatus, v)
+	}
+
+	resp, _ := v.(map[string]interface{})
+	jerr, ok := resp["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Response %v has no error", v)
+	} else if jerr["code"] != float64(expCode) {
+		t.Fatalf("Error code is %v, not %d", jerr["code"], expCode)
+	}
+}
+
+func newGatewayHandler(t *testing.T) *Handler {
+	h := NewHandler()
+	if err := h.RegisterName("sizer", &sizer{size: 5}, false); err != nil {
+		t.Fatalf("RegisterName failed: %v", err)
+	}
+
+	err := h.RegisterFunc("greet", func(p struct {
+		Name string `xmlrpc:"name"`
+	}) map[string]string {
+		return map[string]string{"greeting": "hello " + p.Name}
+	}, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	return h
+}
+
+func checkGateway(t *testing.T, g http.Handler) {
+	status, v := postGateway(t, g, "POST", "/rpc/sizer.setSize", "[12]")
+	if status != http.StatusOK || v != nil {
+		t.Fatalf("SetSize returned %d %v", status, v)
+	}
+
+	status, v = postGateway(t, g, "POST", "/rpc/sizer.getSize", "")
+	if status != http.StatusOK || v != float64(12) {
+		t.Fatalf("GetSize returned %d %v", status, v)
+	}
+
+	status, v = postGateway(t, g, "POST", "/rpc/greet", `{"name": "bob"}`)
+	if m, ok := v.(map[string]interface{}); status != http.StatusOK || !ok ||
+		m["greeting"] != "hello bob" {
+		t.Fatalf("greet returned %d %v", status, v)
+	}
+
+	status, v = postGateway(t, g, "POST", "/rpc/sizer.fail", `["no"]`)
+	checkGatewayError(t, status, v, http.StatusInternalServerError, 17)
+
+	status, v = postGateway(t, g, "POST", "/rpc/nope", "[]")
+	checkGatewayError(t, status, v, http.StatusNotFound, errUnknownMethod)
+
+	status, v = postGateway(t, g, "POST", "/rpc/sizer.setSize", `["x"]`)
+	checkGatewayError(t, status, v, http.StatusBadRequest, errInvalidParams)
+
+	status, v = postGateway(t, g, "POST", "/rpc/sizer.setSize", `[1`)
+	checkGatewayError(t, status, v, http.StatusBadRequest, errNotWellFormed)
+
+	status, v = postGateway(t, g, "POST", "/rpc/sizer.setSize", `12`)
+	checkGatewayError(t, status, v, http.StatusBadRequest, errNotWellFormed)
+
+	status, v = postGateway(t, g, "GET", "/rpc/sizer.getSize", "")
+	checkGatewayError(t, status, v, http.StatusMethodNotAllowed,
+		errInvalidRequest)
+
+	status, v = postGateway(t, g, "POST", "/rpc/", "")
+	checkGatewayError(t, status, v, http.StatusNotFound, errUnknownMethod)
+}
+
+func TestLocalGateway(t *testing.T) {
+	checkGateway(t, NewLocalGateway(newGatewayHandler(t)))
+}
+
+func TestClientGateway(t *testing.T) {
+	srvr := httptest.NewServer(newGatewayHandler(t))
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	checkGateway(t, NewGateway(client))
+}
+
+func TestGatewayBadBackend(t *testing.T) {
+	srvr := httptest.NewServer(http.NotFoundHandler())
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	g := NewGateway(client)
+	g.Prefix = "/api/"
+
+	status, v := postGateway(t, g, "POST", "/api/sizer.getSize", "")
+	checkGatewayError(t, status, v, http.StatusBadGateway, errInternal)
+}
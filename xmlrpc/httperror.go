@@ -0,0 +1,74 @@
+package xmlrpc
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// maximum number of body bytes kept in an HTTPError
+const maxErrorBody = 512
+
+// An HTTPError is returned by a Client when the server's response is not
+// an XML-RPC document, either because the HTTP status is not 200 OK or
+// because the content isn't XML (e.g. an HTML error page from a proxy).
+type HTTPError struct {
+	StatusCode  int
+	Status      string
+	ContentType string
+	Header      http.Header
+
+	// the start of the response body
+	Body []byte
+}
+
+// Return a description of the HTTP error
+func (e *HTTPError) Error() string {
+	var msg string
+	if e.StatusCode != http.StatusOK {
+		msg = fmt.Sprintf("HTTP error %s", e.Status)
+	} else {
+		msg = fmt.Sprintf("Unexpected content type \"%s\"", e.ContentType)
+	}
+
+	if len(e.Body) > 0 {
+		msg += ": " + strings.TrimSpace(string(e.Body))
+	}
+
+	return msg
+}
+
+// is the Content-Type header missing or an XML type?
+func isXMLContentType(ctype string) bool {
+	if ctype == "" {
+		return true
+	}
+
+	mtype, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+
+	return mtype == "text/xml" || mtype == "application/xml" ||
+		strings.HasSuffix(mtype, "+xml")
+}
+
+// return an HTTPError if the response doesn't contain an XML-RPC document
+// (or a JSON-RPC document if 'wantJSON' is true)
+func checkResponse(r *http.Response, wantJSON bool) error {
+	ctype := r.Header.Get("Content-Type")
+	if r.StatusCode == http.StatusOK {
+		if wantJSON && isJSONContentType(ctype) {
+			return nil
+		} else if !wantJSON && isXMLContentType(ctype) {
+			return nil
+		}
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxErrorBody))
+
+	return &HTTPError{StatusCode: r.StatusCode, Status: r.Status,
+		ContentType: ctype, Header: r.Header, Body: body}
+}
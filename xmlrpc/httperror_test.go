@@ -0,0 +1,108 @@
+package xmlrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// start a server which always sends the same response
+func newCannedServer(status int, ctype string,
+	body string) *httptest.Server {
+	srvr, _ := newRecordingServer(func(w http.ResponseWriter,
+		r *http.Request, n int) {
+		if ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	})
+	return srvr
+}
+
+func TestHTTPErrorStatus(t *testing.T) {
+	page := "<html><body>" + strings.Repeat("Not here! ", 100) +
+		"</body></html>"
+
+	srvr := newCannedServer(http.StatusNotFound, "text/html", page)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	_, err, fault := client.RPCCall("foo")
+	if fault != nil {
+		t.Fatalf("Returned fault %v", fault)
+	}
+
+	herr, ok := err.(*HTTPError)
+	if !ok {
+		t.Fatalf("Returned %T error %v, not *HTTPError", err, err)
+	} else if herr.StatusCode != http.StatusNotFound {
+		t.Fatalf("HTTPError has status %d, not 404", herr.StatusCode)
+	} else if herr.Header.Get("Content-Type") != "text/html" {
+		t.Fatalf("HTTPError has headers %v", herr.Header)
+	} else if len(herr.Body) != maxErrorBody {
+		t.Fatalf("HTTPError body has %d bytes, not %d", len(herr.Body),
+			maxErrorBody)
+	} else if !strings.HasPrefix(err.Error(), "HTTP error 404 Not Found:"+
+		" <html><body>Not here!") {
+		t.Fatalf("Unexpected error message \"%v\"", err)
+	}
+}
+
+func TestHTTPErrorServerFault(t *testing.T) {
+	// XML sent with a 500 status is still an HTTP error
+	srvr := newCannedServer(http.StatusInternalServerError, "text/xml",
+		"<?xml version=\"1.0\"?><methodResponse/>")
+	defer srvr.Close()
+
+	client, _ := NewClientURL(srvr.URL)
+	if _, err, _ := client.RPCCall("foo"); err == nil {
+		t.Fatal("500 response did not return an error")
+	} else if herr, ok := err.(*HTTPError); !ok || herr.StatusCode != 500 {
+		t.Fatalf("Returned unexpected error %v", err)
+	}
+}
+
+func TestHTTPErrorContentType(t *testing.T) {
+	srvr := newCannedServer(http.StatusOK, "text/html; charset=utf-8",
+		"<html>Login required</html>")
+	defer srvr.Close()
+
+	client, _ := NewClientURL(srvr.URL)
+
+	_, err, _ := client.RPCCall("foo")
+	if herr, ok := err.(*HTTPError); !ok {
+		t.Fatalf("Returned %T error %v, not *HTTPError", err, err)
+	} else if herr.StatusCode != http.StatusOK ||
+		herr.ContentType != "text/html; charset=utf-8" {
+		t.Fatalf("Unexpected HTTPError %#v", herr)
+	} else if err.Error() != "Unexpected content type"+
+		" \"text/html; charset=utf-8\": <html>Login required</html>" {
+		t.Fatalf("Unexpected error message \"%v\"", err)
+	}
+}
+
+func TestHTTPErrorXMLFault(t *testing.T) {
+	buf := httptest.NewRecorder()
+	writeFault(buf, 12, "Bad things")
+
+	for _, ctype := range []string{"text/xml", "application/xml",
+		"application/xml; charset=UTF-8", "application/soap+xml", ""} {
+		srvr := newCannedServer(http.StatusOK, ctype, buf.Body.String())
+
+		client, _ := NewClientURL(srvr.URL)
+		_, err, fault := client.RPCCall("foo")
+		srvr.Close()
+
+		if err != nil {
+			t.Fatalf("Content type \"%s\" returned error %v", ctype, err)
+		} else if fault == nil || fault.Code != 12 {
+			t.Fatalf("Content type \"%s\" returned fault %v", ctype, fault)
+		}
+	}
+}
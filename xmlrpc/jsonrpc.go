@@ -0,0 +1,373 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// JSON-RPC protocol version sent in every request and response
+const jsonRPCVersion = "2.0"
+
+// a JSON-RPC 2.0 request or notification
+//
+// ID is nil for a notification and "null" for a request whose id is null
+type jsonRequest struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+// a JSON-RPC 2.0 error object
+type jsonError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// a JSON-RPC 2.0 response
+//
+// Result is only omitted from error responses; a nil result is sent as
+// the JSON value "null"
+type jsonResponse struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *jsonError      `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// return true if the Content-Type header specifies a JSON document
+func isJSONContentType(ctype string) bool {
+	mtype, _, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return false
+	}
+
+	return mtype == "application/json" || strings.HasSuffix(mtype, "+json")
+}
+
+// build an error response
+func jsonErrorResponse(id json.RawMessage, code int,
+	msg string) *jsonResponse {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	return &jsonResponse{Version: jsonRPCVersion, ID: id,
+		Error: &jsonError{Code: code, Message: msg}}
+}
+
+// decode a JSON document, keeping numbers as json.Number so integers
+// aren't turned into floats
+func decodeJSON(data []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return fromJSONValue(v), nil
+}
+
+// convert a decoded JSON value to the types used for XML-RPC values, so
+// integers become int (or float64 if they don't fit) and other numbers
+// become float64
+func fromJSONValue(v interface{}) interface{} {
+	switch jv := v.(type) {
+	case json.Number:
+		if i, err := strconv.Atoi(jv.String()); err == nil {
+			return i
+		}
+
+		f, _ := strconv.ParseFloat(jv.String(), 64)
+		return f
+	case []interface{}:
+		for i := range jv {
+			jv[i] = fromJSONValue(jv[i])
+		}
+	case map[string]interface{}:
+		for k, mv := range jv {
+			jv[k] = fromJSONValue(mv)
+		}
+	}
+
+	return v
+}
+
+// convert a Go value to one which encoding/json marshals the same way the
+// value would be sent over XML-RPC (using the `xmlrpc:"name"` tags of Go
+// structs and sending times in ISO8601 format)
+func toJSONValue(val reflect.Value) (interface{}, error) {
+	switch val.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Interface, reflect.Ptr:
+		if val.IsNil() {
+			return nil, nil
+		}
+
+		return toJSONValue(val.Elem())
+	case reflect.Bool, reflect.String:
+		return val.Interface(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return val.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		return val.Uint(), nil
+	case reflect.Float32, reflect.Float64:
+		return val.Float(), nil
+	case reflect.Slice, reflect.Array:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			data := make([]byte, val.Len())
+			reflect.Copy(reflect.ValueOf(data), val)
+			return base64.StdEncoding.EncodeToString(data), nil
+		}
+
+		list := make([]interface{}, val.Len())
+		for i := 0; i < val.Len(); i++ {
+			v, err := toJSONValue(val.Index(i))
+			if err != nil {
+				return nil, err
+			}
+
+			list[i] = v
+		}
+
+		return list, nil
+	case reflect.Map:
+		if val.Type().Key().Kind() != reflect.String {
+			break
+		}
+
+		m := make(map[string]interface{}, val.Len())
+		for _, key := range val.MapKeys() {
+			v, err := toJSONValue(val.MapIndex(key))
+			if err != nil {
+				return nil, err
+			}
+
+			m[key.String()] = v
+		}
+
+		return m, nil
+	case reflect.Struct:
+		if val.Type() == timeType {
+			tm := val.Interface().(time.Time)
+			return tm.Format(ISO8601_LAYOUT), nil
+		}
+
+		m := make(map[string]interface{})
+		st := val.Type()
+		for i := 0; i < st.NumField(); i++ {
+			name, omitEmpty, ok := fieldName(st.Field(i))
+			if !ok || (omitEmpty && val.Field(i).IsZero()) {
+				continue
+			}
+
+			v, err := toJSONValue(val.Field(i))
+			if err != nil {
+				return nil, err
+			}
+
+			m[name] = v
+		}
+
+		return m, nil
+	}
+
+	return nil, fmt.Errorf("Cannot convert %v to JSON", val.Type())
+}
+
+// marshal a Go value as JSON
+func marshalJSONValue(v interface{}) (json.RawMessage, error) {
+	jv, err := toJSONValue(reflect.ValueOf(v))
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jv)
+}
+
+// run a single JSON-RPC request, returning nil for a notification
+func (h *Handler) jsonCall(ctx context.Context,
+	raw json.RawMessage) *jsonResponse {
+	var jreq jsonRequest
+	if err := json.Unmarshal(raw, &jreq); err != nil {
+		return jsonErrorResponse(nil, errInvalidRequest,
+			fmt.Sprintf("Invalid request: %v", err))
+	}
+
+	if len(jreq.ID) > 0 {
+		switch jreq.ID[0] {
+		case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8',
+			'9':
+		default:
+			return jsonErrorResponse(nil, errInvalidRequest,
+				"Invalid request: id must be a string, number or null")
+		}
+	}
+
+	if jreq.Version != jsonRPCVersion {
+		return jsonErrorResponse(jreq.ID, errInvalidRequest,
+			fmt.Sprintf("Invalid request: unsupported version \"%s\"",
+				jreq.Version))
+	} else if jreq.Method == "" {
+		return jsonErrorResponse(jreq.ID, errInvalidRequest,
+			"Invalid request: no method name")
+	}
+
+	var params []interface{}
+	if len(jreq.Params) > 0 {
+		pval, err := decodeJSON(jreq.Params)
+		if err != nil {
+			return jsonErrorResponse(jreq.ID, errInvalidRequest,
+				fmt.Sprintf("Invalid request: %v", err))
+		}
+
+		switch pv := pval.(type) {
+		case nil:
+		case []interface{}:
+			params = pv
+		case map[string]interface{}:
+			// named parameters are passed as a single struct
+			params = []interface{}{pv}
+		default:
+			return jsonErrorResponse(jreq.ID, errInvalidRequest,
+				"Invalid request: params must be an array or object")
+		}
+	}
+
+	results, fault := h.dispatch(ctx, jreq.Method, params)
+	if jreq.ID == nil {
+		return nil
+	} else if fault != nil {
+		return jsonErrorResponse(jreq.ID, fault.Code, fault.Msg)
+	}
+
+	result, err := marshalJSONValue(extractParams(results))
+	if err != nil {
+		return jsonErrorResponse(jreq.ID, errInternal,
+			fmt.Sprintf("Failed to marshal %s: %v", jreq.Method, err))
+	}
+
+	return &jsonResponse{Version: jsonRPCVersion, Result: result,
+		ID: jreq.ID}
+}
+
+// handle a JSON-RPC 2.0 request or batch of requests
+func (h *Handler) handleJSONRequest(resp http.ResponseWriter,
+	req *http.Request) {
+	resp.Header().Set("Content-Type", "application/json")
+
+	body, err := io.ReadAll(req.Body)
+	if err == nil && !json.Valid(body) {
+		err = errors.New("Invalid JSON")
+	}
+	if err != nil {
+		json.NewEncoder(resp).Encode(jsonErrorResponse(nil,
+			errNotWellFormed, fmt.Sprintf("Parse error: %v", err)))
+		return
+	}
+
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		jresp := h.jsonCall(req.Context(), body)
+		if jresp == nil {
+			resp.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		json.NewEncoder(resp).Encode(jresp)
+		return
+	}
+
+	var batch []json.RawMessage
+	if err = json.Unmarshal(body, &batch); err != nil {
+		json.NewEncoder(resp).Encode(jsonErrorResponse(nil,
+			errNotWellFormed, fmt.Sprintf("Parse error: %v", err)))
+		return
+	} else if len(batch) == 0 {
+		json.NewEncoder(resp).Encode(jsonErrorResponse(nil,
+			errInvalidRequest, "Invalid request: empty batch"))
+		return
+	}
+
+	var responses []*jsonResponse
+	for _, raw := range batch {
+		if jresp := h.jsonCall(req.Context(), raw); jresp != nil {
+			responses = append(responses, jresp)
+		}
+	}
+
+	if len(responses) == 0 {
+		resp.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	json.NewEncoder(resp).Encode(responses)
+}
+
+// send JSON-RPC 2.0 requests instead of XML-RPC requests
+//
+// The server must accept JSON-RPC, as a Handler does.  Arguments and
+// results are converted exactly as they are for XML-RPC, so the same
+// Go types can be used in either mode.
+func WithJSONRPC() ClientOption {
+	return func(c *Client) error {
+		c.jsonRPC = true
+		return nil
+	}
+}
+
+// build the body of a JSON-RPC request
+func (c *Client) marshalJSONRequest(methodName string,
+	args []interface{}) ([]byte, error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	params, err := marshalJSONValue(args)
+	if err != nil {
+		return nil, err
+	}
+
+	id := strconv.FormatUint(atomic.AddUint64(&c.nextID, 1), 10)
+
+	return json.Marshal(&jsonRequest{Version: jsonRPCVersion,
+		Method: methodName, Params: params, ID: json.RawMessage(id)})
+}
+
+// decode a JSON-RPC response
+func unmarshalJSONResponse(r io.Reader) (interface{}, error, *Fault) {
+	var jresp jsonResponse
+	if err := json.NewDecoder(r).Decode(&jresp); err != nil {
+		return nil, err, nil
+	}
+
+	if jresp.Error != nil {
+		return nil, nil, NewFault(jresp.Error.Code, jresp.Error.Message)
+	} else if jresp.Result == nil {
+		return nil, errors.New("JSON-RPC response has no result"), nil
+	}
+
+	val, err := decodeJSON(jresp.Result)
+	if err != nil {
+		return nil, err, nil
+	}
+
+	return val, nil, nil
+}
@@ -0,0 +1,267 @@
+package xmlrpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// send a raw JSON-RPC request to the handler, returning the HTTP status
+// and the decoded response body
+func postJSON(t *testing.T, h *Handler, body string) (int, interface{}) {
+	req, err := http.NewRequest("POST", "/RPC2", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	if rec.Code == http.StatusNoContent {
+		if rec.Body.Len() != 0 {
+			t.Fatalf("204 response has body %s", rec.Body.String())
+		}
+		return rec.Code, nil
+	}
+
+	ctype := rec.Header().Get("Content-Type")
+	if ctype != "application/json" {
+		t.Fatalf("Response has Content-Type \"%s\"", ctype)
+	}
+
+	var v interface{}
+	if err = json.Unmarshal(rec.Body.Bytes(), &v); err != nil {
+		t.Fatalf("Cannot decode response %s: %v", rec.Body.String(), err)
+	}
+
+	return rec.Code, v
+}
+
+// check that a decoded response has the expected id and error code
+func checkJSONError(t *testing.T, v interface{}, id interface{}, code int) {
+	resp, ok := v.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Response %v is not an object", v)
+	}
+
+	if resp["id"] != id {
+		t.Errorf("Response id is %v, not %v", resp["id"], id)
+	}
+
+	jerr, ok := resp["error"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Response %v has no error", v)
+	} else if jerr["code"] != float64(code) {
+		t.Fatalf("Error code is %v, not %d", jerr["code"], code)
+	} else if _, ok := resp["result"]; ok {
+		t.Fatalf("Error response %v also has a result", v)
+	}
+}
+
+func newJSONHandler(t *testing.T) (*Handler, *sizer) {
+	obj := &sizer{}
+
+	h := NewHandler()
+	if err := h.Register(obj, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	err := h.RegisterFunc("add", func(a int, b float64) float64 {
+		return float64(a) + b
+	}, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	return h, obj
+}
+
+func TestJSONRPCRequest(t *testing.T) {
+	h, obj := newJSONHandler(t)
+
+	_, v := postJSON(t, h,
+		`{"jsonrpc": "2.0", "method": "SetSize", "params": [42], "id": 1}`)
+	if resp := v.(map[string]interface{}); resp["id"] != float64(1) {
+		t.Fatalf("Response id is %v, not 1", resp["id"])
+	} else if result, ok := resp["result"]; !ok || result != nil {
+		t.Fatalf("SetSize response %v should have a null result", v)
+	}
+
+	if obj.size != 42 {
+		t.Fatalf("Size is %d, not 42", obj.size)
+	}
+
+	_, v = postJSON(t, h,
+		`{"jsonrpc": "2.0", "method": "add", "params": [2, 0.5], "id": "x"}`)
+	if resp := v.(map[string]interface{}); resp["id"] != "x" {
+		t.Fatalf("Response id is %v, not \"x\"", resp["id"])
+	} else if resp["result"] != 2.5 {
+		t.Fatalf("add returned %v, not 2.5", resp["result"])
+	}
+
+	_, v = postJSON(t, h,
+		`{"jsonrpc": "2.0", "method": "Fail", "params": ["bad"], "id": 3}`)
+	checkJSONError(t, v, float64(3), 17)
+
+	_, v = postJSON(t, h, `{"jsonrpc": "2.0", "method": "nope", "id": 4}`)
+	checkJSONError(t, v, float64(4), errUnknownMethod)
+
+	_, v = postJSON(t, h,
+		`{"jsonrpc": "2.0", "method": "SetSize", "params": ["x"], "id": 5}`)
+	checkJSONError(t, v, float64(5), errInvalidParams)
+}
+
+func TestJSONRPCNamedParams(t *testing.T) {
+	h := NewHandler()
+	err := h.RegisterFunc("greet", func(p struct {
+		Name  string `xmlrpc:"name"`
+		Count int    `xmlrpc:"count"`
+	}) string {
+		return strings.Repeat("hi "+p.Name+" ", p.Count)
+	}, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	_, v := postJSON(t, h, `{"jsonrpc": "2.0", "method": "greet",
+		"params": {"name": "bob", "count": 2}, "id": 1}`)
+	if result := v.(map[string]interface{})["result"]; result !=
+		"hi bob hi bob " {
+		t.Fatalf("greet returned %v", result)
+	}
+}
+
+func TestJSONRPCNotification(t *testing.T) {
+	h, obj := newJSONHandler(t)
+
+	code, _ := postJSON(t, h,
+		`{"jsonrpc": "2.0", "method": "SetSize", "params": [7]}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("Notification returned status %d", code)
+	} else if obj.size != 7 {
+		t.Fatalf("Size is %d, not 7", obj.size)
+	}
+
+	// a notification gets no reply even if it fails
+	code, _ = postJSON(t, h, `{"jsonrpc": "2.0", "method": "nope"}`)
+	if code != http.StatusNoContent {
+		t.Fatalf("Failed notification returned status %d", code)
+	}
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	h, obj := newJSONHandler(t)
+
+	_, v := postJSON(t, h, `[
+		{"jsonrpc": "2.0", "method": "SetSize", "params": [3]},
+		{"jsonrpc": "2.0", "method": "GetSize", "id": 1},
+		{"jsonrpc": "2.0", "method": "nope", "id": 2},
+		{"foo": "bar"},
+		1
+	]`)
+
+	list, ok := v.([]interface{})
+	if !ok || len(list) != 4 {
+		t.Fatalf("Batch returned %v, expected 4 responses", v)
+	}
+
+	if result := list[0].(map[string]interface{})["result"]; result !=
+		float64(3) {
+		t.Fatalf("GetSize returned %v, not 3", result)
+	}
+	checkJSONError(t, list[1], float64(2), errUnknownMethod)
+	checkJSONError(t, list[2], nil, errInvalidRequest)
+	checkJSONError(t, list[3], nil, errInvalidRequest)
+
+	if obj.size != 3 {
+		t.Fatalf("Size is %d, not 3", obj.size)
+	}
+
+	code, _ := postJSON(t, h,
+		`[{"jsonrpc": "2.0", "method": "SetSize", "params": [4]}]`)
+	if code != http.StatusNoContent {
+		t.Fatalf("Batch of notifications returned status %d", code)
+	}
+}
+
+func TestJSONRPCBadRequests(t *testing.T) {
+	h, _ := newJSONHandler(t)
+
+	_, v := postJSON(t, h, `{"jsonrpc": "2.0", "method"`)
+	checkJSONError(t, v, nil, errNotWellFormed)
+
+	_, v = postJSON(t, h, `[]`)
+	checkJSONError(t, v, nil, errInvalidRequest)
+
+	_, v = postJSON(t, h, `{"jsonrpc": "1.0", "method": "GetSize", "id": 1}`)
+	checkJSONError(t, v, float64(1), errInvalidRequest)
+
+	_, v = postJSON(t, h,
+		`{"jsonrpc": "2.0", "method": "GetSize", "params": 1, "id": 1}`)
+	checkJSONError(t, v, float64(1), errInvalidRequest)
+
+	_, v = postJSON(t, h, `{"jsonrpc": "2.0", "method": "GetSize",
+		"id": {"a": 1}}`)
+	checkJSONError(t, v, nil, errInvalidRequest)
+}
+
+func TestJSONRPCClient(t *testing.T) {
+	h, _ := newJSONHandler(t)
+	h.RegisterFunc("echo", func(v interface{}) interface{} { return v },
+		false)
+
+	srvr := httptest.NewServer(h)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL, WithJSONRPC())
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	if _, cerr, fault := client.RPCCall("SetSize", 11); cerr != nil ||
+		fault != nil {
+		t.Fatalf("SetSize failed: %v, %v", cerr, fault)
+	}
+
+	val, cerr, fault := client.RPCCall("GetSize")
+	if cerr != nil || fault != nil {
+		t.Fatalf("GetSize failed: %v, %v", cerr, fault)
+	} else if val != 11 {
+		t.Fatalf("GetSize returned %v<%T>, not 11", val, val)
+	}
+
+	val, cerr, fault = client.RPCCall("echo", map[string]interface{}{
+		"list": []int{1, 2}, "pi": 3.5,
+	})
+	if cerr != nil || fault != nil {
+		t.Fatalf("echo failed: %v, %v", cerr, fault)
+	}
+
+	var reply struct {
+		List []int
+		Pi   float64
+	}
+	if err = Decode(val, &reply); err != nil {
+		t.Fatalf("Cannot decode echo reply %v: %v", val, err)
+	} else if len(reply.List) != 2 || reply.List[1] != 2 ||
+		reply.Pi != 3.5 {
+		t.Fatalf("echo returned %v", val)
+	}
+
+	_, cerr, fault = client.RPCCall("Fail", "oops")
+	if cerr != nil {
+		t.Fatalf("Fail returned error %v", cerr)
+	} else if fault == nil || fault.Code != 17 || fault.Msg != "oops" {
+		t.Fatalf("Fail returned fault %v", fault)
+	}
+
+	// XML-RPC clients still work with the same handler
+	xclient := &Client{urlStr: srvr.URL}
+	if val, cerr, fault = xclient.RPCCall("GetSize"); cerr != nil ||
+		fault != nil || val != 11 {
+		t.Fatalf("XML-RPC GetSize returned %v, %v, %v", val, cerr, fault)
+	}
+}
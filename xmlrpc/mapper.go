@@ -0,0 +1,118 @@
+package xmlrpc
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// split a Go identifier into its CamelCase (or snake_case) words,
+// keeping acronyms such as "RPC" or "ID" together
+func splitWords(name string) []string {
+	var words []string
+
+	runes := []rune(name)
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		if runes[i] == '_' {
+			if i > start {
+				words = append(words, string(runes[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+
+		if i == start || !unicode.IsUpper(runes[i]) {
+			continue
+		}
+
+		prev := runes[i-1]
+		nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if !unicode.IsUpper(prev) || nextLower {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(runes) {
+		words = append(words, string(runes[start:]))
+	}
+
+	return words
+}
+
+// capitalize the first character of a word
+func upperFirst(word string) string {
+	r, n := utf8.DecodeRuneInString(word)
+	return string(unicode.ToUpper(r)) + word[n:]
+}
+
+// join words using lowerCamelCase
+func lowerCamel(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+
+	buf := []string{strings.ToLower(words[0])}
+	for _, w := range words[1:] {
+		buf = append(buf, upperFirst(strings.ToLower(w)))
+	}
+
+	return strings.Join(buf, "")
+}
+
+// Return a name mapper which adds 'prefix' to the front of every name,
+// so PrefixMapper("blog.") maps "GetPost" to "blog.GetPost"
+func PrefixMapper(prefix string) func(string) string {
+	return func(name string) string {
+		return prefix + name
+	}
+}
+
+// Name mapper which converts "GetSize" to "getSize" and "RPCGetSize" to
+// "rpcGetSize"
+func LowerCamelMapper(name string) string {
+	return lowerCamel(splitWords(name))
+}
+
+// Name mapper which converts "GetSize" to "get_size" and "RPCGetSize" to
+// "rpc_get_size"
+func SnakeCaseMapper(name string) string {
+	words := splitWords(name)
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+
+	return strings.Join(words, "_")
+}
+
+// Name mapper which treats the first word of the name as a namespace,
+// so "SystemListMethods" becomes "system.listMethods" and "BlogGetPost"
+// becomes "blog.getPost"
+func DottedMapper(name string) string {
+	words := splitWords(name)
+	if len(words) < 2 {
+		return lowerCamel(words)
+	}
+
+	return strings.ToLower(words[0]) + "." + lowerCamel(words[1:])
+}
+
+// Return a name mapper which passes each name through all the mappers in
+// order, ignoring the method if any of them returns ""
+func ChainMappers(mappers ...func(string) string) func(string) string {
+	return func(name string) string {
+		for _, m := range mappers {
+			if m == nil {
+				continue
+			}
+
+			name = m(name)
+			if name == "" {
+				break
+			}
+		}
+
+		return name
+	}
+}
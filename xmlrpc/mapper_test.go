@@ -0,0 +1,133 @@
+package xmlrpc
+
+import (
+	"strings"
+	"testing"
+)
+
+func checkMapper(t *testing.T, mname string, mapper func(string) string,
+	tests map[string]string) {
+	for name, expName := range tests {
+		if got := mapper(name); got != expName {
+			t.Errorf("%s(\"%s\") returned \"%s\", not \"%s\"", mname, name,
+				got, expName)
+		}
+	}
+}
+
+func TestLowerCamelMapper(t *testing.T) {
+	checkMapper(t, "LowerCamelMapper", LowerCamelMapper, map[string]string{
+		"GetSize":    "getSize",
+		"RPCGetSize": "rpcGetSize",
+		"ID":         "id",
+		"GetID":      "getId",
+		"Get_Size":   "getSize",
+		"X":          "x",
+	})
+}
+
+func TestSnakeCaseMapper(t *testing.T) {
+	checkMapper(t, "SnakeCaseMapper", SnakeCaseMapper, map[string]string{
+		"GetSize":    "get_size",
+		"RPCGetSize": "rpc_get_size",
+		"GetHTTPURL": "get_httpurl",
+		"Get2Things": "get2_things",
+	})
+}
+
+func TestDottedMapper(t *testing.T) {
+	checkMapper(t, "DottedMapper", DottedMapper, map[string]string{
+		"SystemListMethods": "system.listMethods",
+		"BlogGetPost":       "blog.getPost",
+		"Ping":              "ping",
+	})
+}
+
+func TestChainMappers(t *testing.T) {
+	ignoreGet := func(name string) string {
+		if strings.HasPrefix(name, "Get") {
+			return ""
+		}
+		return name
+	}
+
+	mapper := ChainMappers(ignoreGet, LowerCamelMapper, PrefixMapper("blog."))
+	checkMapper(t, "ChainMappers", mapper, map[string]string{
+		"NewPost": "blog.newPost",
+		"GetPost": "",
+	})
+}
+
+func TestRegisterName(t *testing.T) {
+	obj := &sizer{size: 12}
+
+	h := NewHandler()
+	if err := h.RegisterName("sizer", obj, false); err != nil {
+		t.Fatalf("RegisterName failed: %v", err)
+	}
+
+	val, fault := callHandler(t, h, "sizer.getSize")
+	if fault != nil {
+		t.Fatalf("sizer.getSize returned fault %v", fault)
+	} else if val != 12 {
+		t.Fatalf("sizer.getSize returned %v, not 12", val)
+	}
+}
+
+func TestRegisterCollision(t *testing.T) {
+	h := NewHandler()
+	if err := h.Register(&sizer{}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := h.Register(&sizer{}, nil, false); err == nil {
+		t.Fatal("Second Register did not report a collision")
+	} else if !strings.Contains(err.Error(), "collides") {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	// "getSize" is only a collision because of the lower-cased alias
+	err := h.RegisterFunc("getSize", func() int { return 0 }, false)
+	if err == nil {
+		t.Fatal("RegisterFunc did not report an alias collision")
+	}
+
+	// a failed registration should not add anything
+	if err = h.RegisterFunc("Other", func() {}, false); err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+	allGet := func(name string) string { return "get" }
+	if err = h.Register(&sizer{}, allGet, false); err == nil {
+		t.Fatal("Register did not report duplicate mapped names")
+	}
+	if _, fault := callHandler(t, h, "get"); fault == nil {
+		t.Fatal("Failed Register added method \"get\"")
+	}
+}
+
+func TestRegisterCaseSensitive(t *testing.T) {
+	h := NewHandler()
+	h.SetCaseInsensitive(false)
+
+	if err := h.Register(&sizer{size: 3}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if _, fault := callHandler(t, h, "getsize"); fault == nil {
+		t.Fatal("Case-sensitive handler accepted \"getsize\"")
+	}
+
+	err := h.RegisterFunc("getSize", func() int { return 4 }, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	for name, expVal := range map[string]int{"GetSize": 3, "getSize": 4} {
+		val, fault := callHandler(t, h, name)
+		if fault != nil {
+			t.Fatalf("%s returned fault %v", name, fault)
+		} else if val != expVal {
+			t.Fatalf("%s returned %v, not %v", name, val, expVal)
+		}
+	}
+}
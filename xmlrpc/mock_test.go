@@ -0,0 +1,116 @@
+package xmlrpc_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+	"github.com/dancebear/go-xmlrpc/xmlrpctest"
+)
+
+func TestBindWithMockServer(t *testing.T) {
+	srv := xmlrpctest.NewServer().InOrder()
+	defer srv.Close()
+
+	srv.Expect("obj.setSize").WithArgs(3)
+	srv.Expect("obj.getSize").Return(3)
+	srv.Expect("obj.getSize").Fault(500, "Gone")
+
+	var obj struct {
+		GetSize func() (int, error) `xmlrpc:"obj.getSize"`
+		SetSize func(int) error     `xmlrpc:"obj.setSize"`
+	}
+
+	if err := srv.Client().Bind(&obj, nil); err != nil {
+		t.Fatalf("Bind failed: %v", err)
+	}
+
+	if err := obj.SetSize(3); err != nil {
+		t.Fatalf("SetSize failed: %v", err)
+	}
+
+	if size, err := obj.GetSize(); err != nil || size != 3 {
+		t.Fatalf("GetSize returned %d, %v", size, err)
+	}
+
+	if _, err := obj.GetSize(); err == nil {
+		t.Fatal("GetSize did not return the fault")
+	} else if fault, ok := err.(*xmlrpc.Fault); !ok || fault.Code != 500 {
+		t.Fatalf("GetSize returned %v", err)
+	}
+
+	srv.Verify(t)
+}
+
+func TestGoWithMockServer(t *testing.T) {
+	srv := xmlrpctest.NewServer()
+	defer srv.Close()
+
+	srv.Expect("ListNames").WithArgs("a*").Return([]string{"al", "ann"})
+
+	var names []string
+	call := srv.Client().Go("ListNames", []interface{}{"a*"}, &names, nil)
+	<-call.Done
+
+	if call.Error != nil {
+		t.Fatalf("ListNames failed: %v", call.Error)
+	} else if len(names) != 2 || names[0] != "al" || names[1] != "ann" {
+		t.Fatalf("ListNames returned %v", names)
+	}
+
+	srv.Verify(t)
+}
+
+func TestRoundTripWithMockServer(t *testing.T) {
+	when, err := time.Parse(xmlrpc.ISO8601_LAYOUT, "19980717T14:08:55")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		val      interface{}
+		expected interface{}
+	}{
+		{true, true},
+		{123456, 123456},
+		{-433221, -433221},
+		{123.456, 123.456},
+		{"abc123", "abc123"},
+		{"", ""},
+		{"</value> & more", "</value> & more"},
+		{1e-7, 1e-7},
+		{nil, nil},
+		{when, when},
+		{[]byte("you can't read this!"), []byte("you can't read this!")},
+		// arrays are always decoded as []interface{}
+		{[]int{1, -1, 0, 1234567}, []interface{}{1, -1, 0, 1234567}},
+		{map[string]interface{}{"boolVal": true, "intVal": 18,
+			"listVal": []interface{}{1, "two"}},
+			map[string]interface{}{"boolVal": true, "intVal": 18,
+				"listVal": []interface{}{1, "two"}}},
+		{struct {
+			Name  string
+			Count int    `xmlrpc:"count"`
+			Skip  string `xmlrpc:"-"`
+		}{"abc", 3, "x"}, map[string]interface{}{"Name": "abc", "count": 3}},
+	}
+
+	for _, test := range tests {
+		srv := xmlrpctest.NewServer()
+
+		// the server only matches if it decodes the expected arguments
+		srv.Expect("echo").WithArgs(test.expected).Return(test.val)
+
+		reply, err, fault := srv.Client().RPCCall("echo", test.val)
+		if err != nil || fault != nil {
+			t.Errorf("%#v: returned %v, %v", test.val, err, fault)
+		} else if !reflect.DeepEqual(reply, test.expected) {
+			t.Errorf("%#v: returned %#v, not %#v", test.val, reply,
+				test.expected)
+		}
+
+		srv.Verify(t)
+		srv.Close()
+	}
+}
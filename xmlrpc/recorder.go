@@ -0,0 +1,365 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// prefixes used in recording files
+const (
+	recRequest  = "--- request"
+	recResponse = "--- response "
+	recLine     = "| "
+)
+
+// a recorded request and its response
+type recordedCall struct {
+	request     string
+	status      int
+	contentType string
+	response    string
+
+	// true once the call has been replayed
+	used bool
+}
+
+// A Recorder is an http.RoundTripper which records the XML-RPC requests
+// sent by a Client along with the server's responses, or replays
+// previously recorded responses without contacting a server, so tests can
+// run without a live server:
+//
+//	rec, err := xmlrpc.NewReplayer("testdata/blog.rec")
+//	client, err := xmlrpc.NewClientURL(url, xmlrpc.WithTransport(rec))
+//	...
+//	if err := rec.Close(); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// Requests and responses are stored in a text file in a normalized XML
+// form, so they can be reviewed and compared like any other golden file.
+// When replaying, each request is matched against the first unused
+// recording of an identical request; a request with no recording fails
+// with an error, as does Close if any recordings were never used.
+type Recorder struct {
+	path      string
+	transport http.RoundTripper
+	recording bool
+
+	mutex      sync.Mutex
+	calls      []*recordedCall
+	unexpected []string
+}
+
+// create a Recorder which sends requests using 'transport' (or
+// http.DefaultTransport if it's nil) and writes the requests and
+// responses to 'path' when it's closed
+func NewRecorder(path string, transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	return &Recorder{path: path, transport: transport, recording: true}
+}
+
+// create a Recorder which replays the requests and responses recorded in
+// 'path'
+func NewReplayer(path string) (*Recorder, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	calls, err := readRecording(fd)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	return &Recorder{path: path, calls: calls}, nil
+}
+
+// return the normalized form of an XML-RPC document, or the original
+// data if it isn't a valid document
+func normalizeXML(data []byte) string {
+	doc, err := ParseDocument(bytes.NewReader(data))
+	if err != nil {
+		return string(data)
+	}
+
+	var buf bytes.Buffer
+	if err = doc.WriteXML(&buf); err != nil {
+		return string(data)
+	}
+
+	return buf.String()
+}
+
+// return the decompressed form of a body sent with the Content-Encoding
+func decodeBytes(data []byte, encoding string) ([]byte, error) {
+	if encoding == "" {
+		return data, nil
+	}
+
+	body, err := decodeBody(io.NopCloser(bytes.NewReader(data)), encoding,
+		DefaultMaxBodySize)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(body)
+}
+
+// return a short description of a request for error messages
+func describeRequest(req string) string {
+	if name, err := readMethodName(strings.NewReader(req)); err == nil {
+		return fmt.Sprintf("call to \"%s\"", name)
+	}
+
+	return fmt.Sprintf("request %q", req)
+}
+
+// record or replay a single request
+func (rec *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	plain, err := decodeBytes(body, req.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.recording {
+		return rec.record(req, body, plain)
+	}
+
+	return rec.replay(req, normalizeXML(plain))
+}
+
+// send the request to the server and record the response
+func (rec *Recorder) record(req *http.Request, body []byte,
+	plain []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	out.Body = io.NopCloser(bytes.NewReader(body))
+	out.ContentLength = int64(len(body))
+
+	resp, err := rec.transport.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	// recordings and the response passed on are always uncompressed
+	if encoding := resp.Header.Get("Content-Encoding"); encoding != "" {
+		if data, err = decodeBytes(data, encoding); err != nil {
+			return nil, err
+		}
+		resp.Header.Del("Content-Encoding")
+	}
+
+	call := &recordedCall{request: normalizeXML(plain),
+		status: resp.StatusCode, contentType: resp.Header.Get("Content-Type"),
+		response: normalizeXML(data)}
+
+	rec.mutex.Lock()
+	rec.calls = append(rec.calls, call)
+	rec.mutex.Unlock()
+
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	resp.ContentLength = int64(len(data))
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+// return the recorded response to the request
+func (rec *Recorder) replay(req *http.Request,
+	body string) (*http.Response, error) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	var call *recordedCall
+	for _, c := range rec.calls {
+		if !c.used && c.request == body {
+			call = c
+			break
+		}
+	}
+
+	if call == nil {
+		desc := describeRequest(body)
+		rec.unexpected = append(rec.unexpected, desc)
+		return nil, fmt.Errorf("Unexpected %s (not recorded in %s)", desc,
+			rec.path)
+	}
+
+	call.used = true
+
+	header := make(http.Header)
+	if call.contentType != "" {
+		header.Set("Content-Type", call.contentType)
+	}
+
+	status := fmt.Sprintf("%d %s", call.status, http.StatusText(call.status))
+
+	return &http.Response{
+		Status:        status,
+		StatusCode:    call.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(call.response)),
+		ContentLength: int64(len(call.response)),
+		Request:       req,
+	}, nil
+}
+
+// write the recorded calls, or report any unexpected requests and any
+// recordings which were never replayed
+func (rec *Recorder) Close() error {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	if rec.recording {
+		fd, err := os.Create(rec.path)
+		if err != nil {
+			return err
+		}
+
+		werr := writeRecording(fd, rec.calls)
+		if cerr := fd.Close(); werr == nil {
+			werr = cerr
+		}
+		return werr
+	}
+
+	var problems []string
+	problems = append(problems, rec.unexpected...)
+	for _, c := range rec.calls {
+		if !c.used {
+			problems = append(problems, "unused recording of "+
+				describeRequest(c.request))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %s", rec.path, strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// write a block of text with each line prefixed so it can't be mistaken
+// for a marker
+func writeRecordedText(w *bufio.Writer, text string) {
+	for _, line := range strings.Split(text, "\n") {
+		w.WriteString(recLine)
+		w.WriteString(line)
+		w.WriteByte('\n')
+	}
+}
+
+// write calls in the recording file format
+func writeRecording(w io.Writer, calls []*recordedCall) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range calls {
+		bw.WriteString(recRequest + "\n")
+		writeRecordedText(bw, c.request)
+
+		fmt.Fprintf(bw, "%s%d %s\n", recResponse, c.status, c.contentType)
+		writeRecordedText(bw, c.response)
+	}
+
+	return bw.Flush()
+}
+
+// read calls from a recording file
+func readRecording(r io.Reader) ([]*recordedCall, error) {
+	var calls []*recordedCall
+
+	// text currently being read, or nil between blocks
+	var text *string
+	var lines []string
+
+	flush := func() {
+		if text != nil {
+			*text = strings.Join(lines, "\n")
+		}
+		text = nil
+		lines = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := scanner.Text()
+
+		switch {
+		case strings.HasPrefix(line, recLine):
+			if text == nil {
+				return nil, fmt.Errorf("line %d: text outside a block",
+					lineNum)
+			}
+			lines = append(lines, line[len(recLine):])
+		case line == recRequest:
+			flush()
+			calls = append(calls, &recordedCall{})
+			text = &calls[len(calls)-1].request
+		case strings.HasPrefix(line, recResponse):
+			flush()
+			if len(calls) == 0 {
+				return nil, fmt.Errorf("line %d: response without request",
+					lineNum)
+			}
+
+			c := calls[len(calls)-1]
+			fields := strings.SplitN(line[len(recResponse):], " ", 2)
+			status, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: bad status \"%s\"",
+					lineNum, fields[0])
+			}
+
+			c.status = status
+			if len(fields) > 1 {
+				c.contentType = fields[1]
+			}
+			text = &c.response
+		default:
+			return nil, fmt.Errorf("line %d: unexpected \"%s\"", lineNum,
+				line)
+		}
+	}
+	flush()
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range calls {
+		if c.status == 0 {
+			return nil, errors.New("request without response")
+		}
+	}
+
+	return calls, nil
+}
@@ -0,0 +1,206 @@
+package xmlrpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// make the calls used by the recorder tests
+func recorderCalls(t *testing.T, client *Client) {
+	if _, cerr, fault := client.RPCCall("SetSize", 8); cerr != nil ||
+		fault != nil {
+		t.Fatalf("SetSize failed: %v, %v", cerr, fault)
+	}
+
+	if val, cerr, fault := client.RPCCall("GetSize"); cerr != nil ||
+		fault != nil || val != 8 {
+		t.Fatalf("GetSize returned %v, %v, %v", val, cerr, fault)
+	}
+
+	// text which looks like the recording format is stored safely
+	msg := "a\n--- request\n| b"
+	if _, cerr, fault := client.RPCCall("Fail", msg); cerr != nil ||
+		fault == nil || fault.Msg != msg {
+		t.Fatalf("Fail returned %v, %v", cerr, fault)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sizer.rec")
+
+	h := NewHandler()
+	if err := h.Register(&sizer{}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	srvr := httptest.NewServer(h)
+	url := srvr.URL
+
+	rec := NewRecorder(path, nil)
+	client, err := NewClientURL(url, WithTransport(rec))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	recorderCalls(t, client)
+	if err = rec.Close(); err != nil {
+		t.Fatalf("Cannot write recording: %v", err)
+	}
+
+	// the server is no longer needed
+	srvr.Close()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Cannot read recording: %v", err)
+	} else if !strings.Contains(string(data),
+		"| <methodCall>\n|   <methodName>GetSize</methodName>\n") {
+		t.Fatalf("Recording does not hold normalized XML:\n%s", data)
+	}
+
+	rep, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+
+	client, err = NewClientURL(url, WithTransport(rep))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	recorderCalls(t, client)
+	if err = rep.Close(); err != nil {
+		t.Fatalf("Replay reported %v", err)
+	}
+}
+
+func TestReplayMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sizer.rec")
+
+	recording := `--- request
+| <?xml version="1.0"?>
+| <methodCall>
+|   <methodName>GetSize</methodName>
+|   <params>
+|   </params>
+| </methodCall>
+| 
+--- response 200 text/xml
+| <?xml version="1.0"?>
+| <methodResponse>
+|   <params>
+|     <param>
+|       <value><int>5</int></value>
+|     </param>
+|   </params>
+| </methodResponse>
+| 
+--- request
+| <?xml version="1.0"?>
+| <methodCall>
+|   <methodName>Unused</methodName>
+|   <params>
+|   </params>
+| </methodCall>
+| 
+--- response 503 text/html
+| <html>down</html>
+`
+	if err := os.WriteFile(path, []byte(recording), 0644); err != nil {
+		t.Fatalf("Cannot write recording: %v", err)
+	}
+
+	rep, err := NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer failed: %v", err)
+	}
+
+	client, err := NewClientURL("http://example.invalid/RPC2",
+		WithTransport(rep))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	if val, cerr, fault := client.RPCCall("GetSize"); cerr != nil ||
+		fault != nil || val != 5 {
+		t.Fatalf("GetSize returned %v, %v, %v", val, cerr, fault)
+	}
+
+	// the only recording of GetSize has been used
+	if _, cerr, _ := client.RPCCall("GetSize"); cerr == nil {
+		t.Fatal("Second GetSize was not reported as unexpected")
+	}
+
+	if _, cerr, _ := client.RPCCall("SetSize", 1); cerr == nil ||
+		!strings.Contains(cerr.Error(), "\"SetSize\"") {
+		t.Fatalf("SetSize returned %v", cerr)
+	}
+
+	err = rep.Close()
+	if err == nil {
+		t.Fatal("Close did not report problems")
+	}
+
+	for _, s := range []string{"\"GetSize\"", "\"SetSize\"",
+		"unused recording of call to \"Unused\""} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Close error \"%v\" does not mention %s", err, s)
+		}
+	}
+}
+
+func TestReplayBadFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for i, text := range []string{
+		"| orphan text\n",
+		"--- response 200 text/xml\n| x\n",
+		"--- request\n| x\n--- response abc\n",
+		"--- request\n| x\n",
+		"something else\n",
+	} {
+		path := filepath.Join(dir, "bad.rec")
+		if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+			t.Fatalf("Cannot write recording: %v", err)
+		}
+
+		if _, err := NewReplayer(path); err == nil {
+			t.Errorf("NewReplayer accepted bad file #%d", i)
+		}
+	}
+}
+
+func TestRecordBadEncoding(t *testing.T) {
+	// the response claims to be compressed, but isn't
+	srvr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter,
+		r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write([]byte("not gzip"))
+	}))
+	defer srvr.Close()
+
+	path := filepath.Join(t.TempDir(), "bad.rec")
+	rec := NewRecorder(path, nil)
+	client, err := NewClientURL(srvr.URL, WithTransport(rec))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	if _, cerr, _ := client.RPCCall("GetSize"); cerr == nil {
+		t.Fatal("Badly compressed response was accepted")
+	}
+
+	if err = rec.Close(); err != nil {
+		t.Fatalf("Cannot write recording: %v", err)
+	}
+
+	if data, err := os.ReadFile(path); err != nil {
+		t.Fatalf("Cannot read recording: %v", err)
+	} else if strings.Contains(string(data), "not gzip") {
+		t.Fatalf("Badly compressed response was recorded:\n%s", data)
+	}
+}
@@ -0,0 +1,192 @@
+package xmlrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// A RetryPolicy describes how a Client retries failed calls.  Calls are
+// only retried for methods which have been marked as idempotent with
+// Client.MarkIdempotent, since other methods may have taken effect on the
+// server before the failure was reported.
+type RetryPolicy struct {
+	// total number of attempts, including the first
+	MaxAttempts int
+
+	// delay before the first retry, which is multiplied by Multiplier
+	// (or 2 if Multiplier is zero) after every attempt, up to MaxBackoff
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// fraction of each delay (0.0 to 1.0) which is randomly added or
+	// subtracted so that clients don't retry in lockstep
+	Jitter float64
+
+	// HTTP status codes which are retried
+	RetryStatus []int
+
+	// XML-RPC fault codes which are retried
+	RetryFaults []int
+
+	// decides whether a transport error is retried; if nil, connection
+	// resets, refused connections, unexpected EOFs and timeouts are
+	// retried
+	RetryError func(error) bool
+}
+
+// return a retry policy which makes up to three attempts, retrying
+// transient network errors along with HTTP 502, 503 and 504 responses
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryStatus:    []int{502, 503, 504},
+	}
+}
+
+// retry failed calls to idempotent methods using the policy
+func WithRetryPolicy(policy *RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		c.SetRetryPolicy(policy)
+		return nil
+	}
+}
+
+// mark the methods as idempotent, so they are retried according to the
+// retry policy
+func WithIdempotent(methods ...string) ClientOption {
+	return func(c *Client) error {
+		c.MarkIdempotent(methods...)
+		return nil
+	}
+}
+
+// set the retry policy (nil disables retries)
+func (c *Client) SetRetryPolicy(policy *RetryPolicy) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.retry = policy
+}
+
+// mark the methods as idempotent (safe to send more than once), so they
+// are retried according to the retry policy
+func (c *Client) MarkIdempotent(methods ...string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.idempotent == nil {
+		c.idempotent = make(map[string]bool)
+	}
+
+	for _, name := range methods {
+		c.idempotent[name] = true
+	}
+}
+
+// return the retry policy for the method, or nil if it isn't retried
+func (c *Client) retryPolicy(methodName string) *RetryPolicy {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.retry == nil || c.retry.MaxAttempts < 2 ||
+		!c.idempotent[methodName] {
+		return nil
+	}
+
+	return c.retry
+}
+
+// is the error likely to go away if the request is sent again?
+func isTransientError(err error) bool {
+	if errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) || errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// should a call which ended with this error, fault or HTTP status be
+// retried?
+func (p *RetryPolicy) shouldRetry(err error, fault *Fault, status int) bool {
+	for _, s := range p.RetryStatus {
+		if status == s {
+			return true
+		}
+	}
+
+	if fault != nil {
+		for _, code := range p.RetryFaults {
+			if fault.Code == code {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if err == nil || status != 0 {
+		return false
+	} else if p.RetryError != nil {
+		return p.RetryError(err)
+	}
+
+	return isTransientError(err)
+}
+
+// return the delay before the next attempt, after 'attempt' attempts
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	mult := p.Multiplier
+	if mult == 0 {
+		mult = 2
+	}
+
+	delay := float64(p.InitialBackoff) * math.Pow(mult, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(delay)
+}
+
+// wait for the delay, returning false without waiting if the context
+// would expire first, or if it's cancelled while waiting
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok &&
+		time.Now().Add(delay).After(deadline) {
+		return false
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
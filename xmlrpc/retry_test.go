@@ -0,0 +1,219 @@
+package xmlrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// return a policy which retries quickly
+func fastRetryPolicy() *RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	return policy
+}
+
+// start a server which runs 'fail' before passing each request to the
+// handler, with the number of requests received so far
+func newFlakyServer(h *Handler,
+	fail func(w http.ResponseWriter, attempt int) bool) (*httptest.Server,
+	*requestLog) {
+	return newRecordingServer(func(w http.ResponseWriter, r *http.Request,
+		n int) {
+		if !fail(w, n) {
+			h.ServeHTTP(w, r)
+		}
+	})
+}
+
+func TestRetryStatus(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("get", func() string { return "ok" }, false)
+	h.RegisterFunc("put", func() string { return "ok" }, false)
+
+	srvr, reqs := newFlakyServer(h, func(w http.ResponseWriter,
+		attempt int) bool {
+		if attempt%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return true
+		}
+		return false
+	})
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL,
+		WithRetryPolicy(fastRetryPolicy()), WithIdempotent("get"))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	val, err, fault := client.RPCCall("get")
+	if err != nil || fault != nil {
+		t.Fatalf("get returned error %v, fault %v", err, fault)
+	} else if val != "ok" {
+		t.Fatalf("get returned %v", val)
+	} else if n := reqs.count(); n != 3 {
+		t.Fatalf("Server saw %d requests, not 3", n)
+	}
+
+	// methods which aren't idempotent are never retried
+	if _, err, _ = client.RPCCall("put"); err == nil {
+		t.Fatal("put succeeded after a 503 response")
+	} else if herr, ok := err.(*HTTPError); !ok || herr.StatusCode != 503 {
+		t.Fatalf("put returned unexpected error %v", err)
+	} else if n := reqs.count(); n != 4 {
+		t.Fatalf("Server saw %d requests, not 4", n)
+	}
+}
+
+func TestRetryMaxAttempts(t *testing.T) {
+	h := NewHandler()
+	srvr, reqs := newFlakyServer(h, func(w http.ResponseWriter,
+		attempt int) bool {
+		w.WriteHeader(http.StatusBadGateway)
+		return true
+	})
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL,
+		WithRetryPolicy(fastRetryPolicy()), WithIdempotent("get"))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	if _, err, _ = client.RPCCall("get"); err == nil {
+		t.Fatal("get succeeded after repeated 502 responses")
+	} else if n := reqs.count(); n != 3 {
+		t.Fatalf("Server saw %d requests, not 3", n)
+	}
+}
+
+func TestRetryConnectionReset(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("get", func() int { return 7 }, false)
+
+	srvr, reqs := newFlakyServer(h, func(w http.ResponseWriter,
+		attempt int) bool {
+		if attempt > 1 {
+			return false
+		}
+
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Cannot hijack connection: %v", err)
+			return true
+		}
+		conn.Close()
+		return true
+	})
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL,
+		WithRetryPolicy(fastRetryPolicy()), WithIdempotent("get"))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	val, err, fault := client.RPCCall("get")
+	if err != nil || fault != nil {
+		t.Fatalf("get returned error %v, fault %v", err, fault)
+	} else if val != 7 {
+		t.Fatalf("get returned %v", val)
+	} else if n := reqs.count(); n != 2 {
+		t.Fatalf("Server saw %d requests, not 2", n)
+	}
+}
+
+func TestRetryFault(t *testing.T) {
+	var calls int32
+
+	h := NewHandler()
+	h.RegisterFunc("get", func() *Fault {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			return NewFault(42, "Busy")
+		}
+		return nil
+	}, false)
+
+	client, done := newTestClient(t, h)
+	defer done()
+
+	client.MarkIdempotent("get")
+	client.SetRetryPolicy(fastRetryPolicy())
+
+	if _, _, fault := client.RPCCall("get"); fault == nil ||
+		fault.Code != 42 {
+		t.Fatalf("Fault 42 was retried (got fault %v)", fault)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+
+	policy := fastRetryPolicy()
+	policy.RetryFaults = []int{42}
+	client.SetRetryPolicy(policy)
+
+	if _, err, fault := client.RPCCall("get"); err != nil || fault != nil {
+		t.Fatalf("get returned error %v, fault %v", err, fault)
+	} else if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Fatalf("get was called %d times, not 2", n)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	h := NewHandler()
+	srvr, reqs := newFlakyServer(h, func(w http.ResponseWriter,
+		attempt int) bool {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	defer srvr.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 10
+	policy.InitialBackoff = time.Second
+	policy.Jitter = 0
+
+	client, err := NewClientURL(srvr.URL, WithRetryPolicy(policy),
+		WithIdempotent("get"))
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(),
+		100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err, _ = client.RPCCallContext(ctx, "get"); err == nil {
+		t.Fatal("get succeeded after a 503 response")
+	} else if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Retry ignored the context deadline (took %v)", elapsed)
+	} else if n := reqs.count(); n != 1 {
+		t.Fatalf("Server saw %d requests, not 1", n)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := &RetryPolicy{InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second, Multiplier: 3}
+
+	expDelays := []time.Duration{100 * time.Millisecond,
+		300 * time.Millisecond, 900 * time.Millisecond, time.Second}
+	for i, exp := range expDelays {
+		if delay := policy.backoff(i + 1); delay != exp {
+			t.Fatalf("Attempt %d delay was %v, not %v", i+1, delay, exp)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.backoff(1)
+		if delay < 50*time.Millisecond || delay > 150*time.Millisecond {
+			t.Fatalf("Jittered delay %v is out of range", delay)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"sync"
+)
+
+// name of the introspection method answered by a Handler and aggregated
+// by a Router
+const listMethodsName = "system.listMethods"
+
+// a pattern and the backend which serves matching methods
+type route struct {
+	pattern string
+	client  *Client
+}
+
+// A Router is an http.Handler which forwards each XML-RPC request to one
+// of several backend servers, chosen by the request's method name.  Only
+// the start of the request is parsed to find the method name; the body is
+// then passed to the backend unchanged and the backend's response is
+// copied back to the client.
+//
+// Calls to "system.listMethods" are answered by the Router itself with
+// the combined list of methods from every backend, limited to the methods
+// which the Router would send to that backend.  Backends which return a
+// fault for "system.listMethods" or can't be reached are left out of the
+// list.
+type Router struct {
+	mutex  sync.RWMutex
+	routes []*route
+}
+
+// create a router with no routes
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// send requests for methods matching 'pattern' to the server used by the
+// Client
+//
+// The pattern uses path.Match syntax, so "blog.*" matches every method in
+// the "blog" namespace and "*" matches every method.  Routes are tried in
+// the order they were added and the first match is used.
+func (rt *Router) Route(pattern string, c *Client) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("Bad pattern \"%s\": %v", pattern, err)
+	} else if c == nil {
+		return errors.New("Nil client")
+	}
+
+	rt.mutex.Lock()
+	defer rt.mutex.Unlock()
+
+	rt.routes = append(rt.routes, &route{pattern: pattern, client: c})
+	return nil
+}
+
+// return the backend which serves 'methodName', or nil if there is none
+func (rt *Router) lookup(methodName string) *Client {
+	rt.mutex.RLock()
+	defer rt.mutex.RUnlock()
+
+	for _, r := range rt.routes {
+		if ok, _ := path.Match(r.pattern, methodName); ok {
+			return r.client
+		}
+	}
+
+	return nil
+}
+
+// read the method name from the start of a <methodCall>
+func readMethodName(r io.Reader) (string, error) {
+	p, err := newXMLDecoder(r, "")
+	if err != nil {
+		return "", err
+	}
+
+	for {
+		tok, err := getNextToken(p)
+		if err != nil {
+			return "", err
+		} else if tok == nil {
+			return "", errors.New("No <methodCall> in request")
+		}
+
+		if tok.IsNone() || tok.IsText() {
+			continue
+		} else if !tok.Is(tokenMethodCall) || !tok.IsStart() {
+			return "", fmt.Errorf("Unexpected %s in request", tok)
+		}
+
+		return getMethodName(p)
+	}
+}
+
+// collect the methods served by every backend
+func (rt *Router) listMethods(req *http.Request) ([]string, error) {
+	rt.mutex.RLock()
+	var clients []*Client
+	seen := make(map[*Client]bool)
+	for _, r := range rt.routes {
+		if !seen[r.client] {
+			seen[r.client] = true
+			clients = append(clients, r.client)
+		}
+	}
+	rt.mutex.RUnlock()
+
+	names := map[string]bool{listMethodsName: true}
+	for _, c := range clients {
+		// a backend without introspection, or one which can't be reached
+		// right now, still gets its calls forwarded, but its methods
+		// can't be listed
+		val, err, fault := c.call(req.Context(), listMethodsName, nil)
+		if err != nil || fault != nil {
+			continue
+		}
+
+		var list []string
+		if err = Decode(val, &list); err != nil {
+			return nil, fmt.Errorf("Bad %s result: %v", listMethodsName,
+				err)
+		}
+
+		for _, name := range list {
+			if rt.lookup(name) == c {
+				names[name] = true
+			}
+		}
+	}
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list, nil
+}
+
+// forward the request body to the backend and copy back the response
+func (rt *Router) forward(resp http.ResponseWriter, req *http.Request,
+	c *Client, body io.Reader) {
+	if !c.acquire(req.Context()) {
+		http.Error(resp, req.Context().Err().Error(),
+			http.StatusServiceUnavailable)
+		return
+	}
+	defer c.release()
+
+	breq, err := http.NewRequest("POST", c.urlStr, body)
+	if err != nil {
+		http.Error(resp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	breq = breq.WithContext(req.Context())
+	breq.Header.Set("Content-Type", req.Header.Get("Content-Type"))
+	if c.hasAuth {
+		breq.SetBasicAuth(c.username, c.password)
+	}
+	c.addHeaders(breq)
+
+	bresp, err := c.Do(breq)
+	if err != nil {
+		http.Error(resp, fmt.Sprintf("Backend error: %v", err),
+			http.StatusBadGateway)
+		return
+	}
+	defer bresp.Body.Close()
+
+	for _, key := range []string{"Content-Type", "Content-Length",
+		"Content-Encoding"} {
+		if val := bresp.Header.Get(key); val != "" {
+			resp.Header().Set(key, val)
+		}
+	}
+
+	resp.WriteHeader(bresp.StatusCode)
+	io.Copy(resp, bresp.Body)
+}
+
+// route an XML-RPC request to a backend
+func (rt *Router) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	// faults are written by the router; forwarded responses replace this
+	resp.Header().Set("Content-Type", "text/xml")
+
+	// the method name can't be found in a compressed body, so decompress
+	// it and forward the decompressed data
+	if encoding := req.Header.Get("Content-Encoding"); encoding != "" {
+		body, err := decodeBody(req.Body, encoding, DefaultMaxBodySize)
+		if err != nil {
+			writeFault(resp, errNotWellFormed,
+				fmt.Sprintf("Cannot decode request: %v", err))
+			return
+		}
+		req.Body = body
+	}
+
+	// remember the bytes read while looking for the method name, so they
+	// can be sent to the backend ahead of the rest of the body
+	var head bytes.Buffer
+	methodName, err := readMethodName(io.TeeReader(req.Body, &head))
+	if err != nil {
+		writeFault(resp, errNotWellFormed,
+			fmt.Sprintf("Unmarshal error: %v", err))
+		return
+	}
+
+	if methodName == listMethodsName {
+		list, err := rt.listMethods(req)
+		if err != nil {
+			writeFault(resp, errInternal,
+				fmt.Sprintf("Cannot list methods: %v", err))
+			return
+		}
+
+		buf := bytes.NewBufferString("")
+		if err = marshalArray(buf, "", []interface{}{list}); err != nil {
+			writeFault(resp, errInternal, err.Error())
+			return
+		}
+
+		buf.WriteTo(resp)
+		return
+	}
+
+	c := rt.lookup(methodName)
+	if c == nil {
+		writeFault(resp, errUnknownMethod,
+			fmt.Sprintf("Unknown method \"%s\"", methodName))
+		return
+	}
+
+	rt.forward(resp, req, c, io.MultiReader(&head, req.Body))
+}
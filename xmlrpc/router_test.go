@@ -0,0 +1,173 @@
+package xmlrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// start a backend serving 'obj' in the 'namespace' namespace, plus any
+// interceptors
+func newBackend(t *testing.T, namespace string, obj interface{},
+	interceptors ...Interceptor) (*Client, func()) {
+	h := NewHandler()
+	if err := h.RegisterName(namespace, obj, false); err != nil {
+		t.Fatalf("RegisterName failed: %v", err)
+	}
+	h.Use(interceptors...)
+
+	srvr := httptest.NewServer(h)
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	return client, srvr.Close
+}
+
+type echoer struct{}
+
+func (e *echoer) Echo(s string) string { return s }
+
+func TestRouter(t *testing.T) {
+	sizeClient, sizeClose := newBackend(t, "size", &sizer{size: 3})
+	defer sizeClose()
+
+	echoClient, echoClose := newBackend(t, "echo", &echoer{})
+	defer echoClose()
+
+	// a backend without introspection
+	legacyClient, legacyClose := newBackend(t, "legacy", &echoer{},
+		func(ctx context.Context, methodName string, params []interface{},
+			next Invoker) ([]interface{}, *Fault) {
+			if methodName == "system.listMethods" {
+				return nil, NewFault(errUnknownMethod, "No introspection")
+			}
+			return next(ctx, methodName, params)
+		})
+	defer legacyClose()
+
+	// a backend which isn't running
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	downClient, err := NewClientURL(down.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	rt := NewRouter()
+	if err := rt.Route("size.*", sizeClient); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if err := rt.Route("echo.*", echoClient); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if err := rt.Route("legacy.*", legacyClient); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if err := rt.Route("down.*", downClient); err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if err := rt.Route("[", echoClient); err == nil {
+		t.Fatal("Route accepted a bad pattern")
+	}
+
+	srvr := httptest.NewServer(rt)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	if val, cerr, fault := client.RPCCall("size.getSize"); cerr != nil ||
+		fault != nil || val != 3 {
+		t.Fatalf("size.getSize returned %v, %v, %v", val, cerr, fault)
+	}
+
+	// a body much larger than the decoder's buffer is passed through intact
+	long := strings.Repeat("0123456789", 2000)
+	if val, cerr, fault := client.RPCCall("echo.echo", long); cerr != nil ||
+		fault != nil || val != long {
+		t.Fatalf("echo.echo failed: %v, %v", cerr, fault)
+	}
+
+	// backend faults are passed back unchanged
+	_, cerr, fault := client.RPCCall("size.fail", "routed")
+	if cerr != nil || fault == nil || fault.Code != 17 ||
+		fault.Msg != "routed" {
+		t.Fatalf("size.fail returned %v, %v", cerr, fault)
+	}
+
+	if val, cerr, fault := client.RPCCall("legacy.echo", "old"); cerr != nil ||
+		fault != nil || val != "old" {
+		t.Fatalf("legacy.echo returned %v, %v, %v", val, cerr, fault)
+	}
+
+	_, cerr, fault = client.RPCCall("nobody.home")
+	if cerr != nil || fault == nil || fault.Code != errUnknownMethod {
+		t.Fatalf("nobody.home returned %v, %v", cerr, fault)
+	}
+
+	val, cerr, fault := client.RPCCall("system.listMethods")
+	if cerr != nil || fault != nil {
+		t.Fatalf("system.listMethods failed: %v, %v", cerr, fault)
+	}
+
+	var names []string
+	if err = Decode(val, &names); err != nil {
+		t.Fatalf("Cannot decode %v: %v", val, err)
+	}
+
+	expNames := []string{"echo.echo", "size.fail", "size.getSize",
+		"size.setSize", "system.listMethods"}
+	if !reflect.DeepEqual(names, expNames) {
+		t.Fatalf("system.listMethods returned %v, not %v", names, expNames)
+	}
+}
+
+func TestRouterErrors(t *testing.T) {
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	downClient, err := NewClientURL(downURL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	rt := NewRouter()
+	rt.Route("*", downClient)
+
+	srvr := httptest.NewServer(rt)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	_, cerr, _ := client.RPCCall("anything")
+	if herr, ok := cerr.(*HTTPError); !ok ||
+		herr.StatusCode != http.StatusBadGateway {
+		t.Fatalf("Call to unreachable backend returned %v", cerr)
+	}
+
+	for _, body := range []string{"", "<methodResponse/>",
+		"<methodCall><params/></methodCall>"} {
+		req := httptest.NewRequest("POST", "/RPC2", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		rt.ServeHTTP(rec, req)
+
+		_, _, uerr, fault := Unmarshal(rec.Body)
+		if uerr != nil || fault == nil || fault.Code != errNotWellFormed {
+			t.Fatalf("Router returned %v, %v for \"%s\"", uerr, fault,
+				body)
+		}
+	}
+}
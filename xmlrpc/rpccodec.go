@@ -0,0 +1,221 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/rpc"
+	"sync"
+)
+
+// XML-RPC fault code for errors returned by net/rpc service methods
+const errApplication = -32500
+
+// the result of a call made through a clientCodec
+type codecResult struct {
+	methodName string
+	seq        uint64
+	reply      interface{}
+	err        error
+}
+
+// net/rpc ClientCodec which sends each request to an XML-RPC server
+type clientCodec struct {
+	client  *Client
+	results chan *codecResult
+	closed  chan struct{}
+	once    sync.Once
+
+	// result whose body is read by ReadResponseBody
+	cur *codecResult
+}
+
+// create a net/rpc ClientCodec which sends requests to the XML-RPC server
+// used by the Client
+//
+// The request's ServiceMethod (e.g. "Arith.Multiply") is used as the
+// XML-RPC method name and the argument is sent as the only parameter.
+func NewClientCodec(c *Client) rpc.ClientCodec {
+	return &clientCodec{client: c, results: make(chan *codecResult),
+		closed: make(chan struct{})}
+}
+
+// create a net/rpc Client which talks to the XML-RPC server used by the
+// Client
+func NewRPCClient(c *Client) *rpc.Client {
+	return rpc.NewClientWithCodec(NewClientCodec(c))
+}
+
+// start sending a request to the server
+func (cc *clientCodec) WriteRequest(r *rpc.Request, body interface{}) error {
+	res := &codecResult{methodName: r.ServiceMethod, seq: r.Seq}
+
+	var args []interface{}
+	if body != nil {
+		args = []interface{}{body}
+	}
+
+	go func() {
+		var fault *Fault
+		res.reply, res.err, fault = cc.client.call(context.Background(),
+			res.methodName, args)
+		if fault != nil {
+			res.err = fault
+		}
+
+		select {
+		case cc.results <- res:
+		case <-cc.closed:
+		}
+	}()
+
+	return nil
+}
+
+// wait for the next response
+func (cc *clientCodec) ReadResponseHeader(r *rpc.Response) error {
+	select {
+	case res := <-cc.results:
+		r.ServiceMethod = res.methodName
+		r.Seq = res.seq
+		if res.err != nil {
+			r.Error = res.err.Error()
+		}
+
+		cc.cur = res
+		return nil
+	case <-cc.closed:
+		return io.EOF
+	}
+}
+
+// decode the current response into the reply
+func (cc *clientCodec) ReadResponseBody(body interface{}) error {
+	if body == nil || cc.cur == nil || cc.cur.err != nil {
+		return nil
+	}
+
+	return Decode(cc.cur.reply, body)
+}
+
+// stop waiting for responses
+func (cc *clientCodec) Close() error {
+	cc.once.Do(func() { close(cc.closed) })
+	return nil
+}
+
+// net/rpc ServerCodec which handles a single XML-RPC request sent over HTTP
+type serverCodec struct {
+	resp http.ResponseWriter
+	req  *http.Request
+
+	// true after the request has been read
+	readReq bool
+	params  []interface{}
+
+	// fault code used if the service reports an error
+	errCode int
+
+	// true after a response has been written
+	responded bool
+}
+
+// create a net/rpc ServerCodec which reads a single XML-RPC request from
+// the HTTP request and writes the reply (or a fault) to the response
+//
+// Pass the codec to rpc.Server.ServeRequest; NewRPCHandler does this for
+// every request.
+func NewServerCodec(resp http.ResponseWriter,
+	req *http.Request) rpc.ServerCodec {
+	return &serverCodec{resp: resp, req: req}
+}
+
+// read the XML-RPC request
+func (sc *serverCodec) ReadRequestHeader(r *rpc.Request) error {
+	if sc.readReq {
+		return io.EOF
+	}
+	sc.readReq = true
+
+	methodName, params, err, fault := unmarshalCharset(sc.req.Body,
+		contentCharset(sc.req.Header.Get("Content-Type")))
+	if err != nil {
+		return err
+	} else if fault != nil {
+		return fault
+	} else if methodName == "" {
+		return errors.New("No method name in request")
+	}
+
+	r.ServiceMethod = methodName
+	r.Seq = 0
+	sc.params = params
+
+	return nil
+}
+
+// convert the XML-RPC parameter into the method's argument
+func (sc *serverCodec) ReadRequestBody(body interface{}) error {
+	if body == nil {
+		// net/rpc discards the body when the method can't be found
+		sc.errCode = errUnknownMethod
+		return nil
+	}
+
+	sc.errCode = errInvalidParams
+	if len(sc.params) > 1 {
+		return fmt.Errorf("Expected 1 parameter, not %d", len(sc.params))
+	} else if len(sc.params) == 1 {
+		if err := Decode(sc.params[0], body); err != nil {
+			return err
+		}
+	}
+
+	sc.errCode = errApplication
+	return nil
+}
+
+// write the XML-RPC response or fault
+func (sc *serverCodec) WriteResponse(r *rpc.Response, body interface{}) error {
+	sc.responded = true
+	sc.resp.Header().Set("Content-Type", "text/xml")
+
+	if r.Error != "" {
+		writeFault(sc.resp, sc.errCode, r.Error)
+		return nil
+	}
+
+	buf := bytes.NewBufferString("")
+	if err := marshalArray(buf, "", []interface{}{body}); err != nil {
+		writeFault(sc.resp, errInternal,
+			fmt.Sprintf("Failed to marshal %s: %v", r.ServiceMethod, err))
+		return nil
+	}
+
+	_, err := buf.WriteTo(sc.resp)
+	return err
+}
+
+// nothing to close, since the HTTP server owns the connection
+func (sc *serverCodec) Close() error {
+	return nil
+}
+
+// create an HTTP handler which serves XML-RPC requests using the net/rpc
+// services registered with 'srv'
+func NewRPCHandler(srv *rpc.Server) http.Handler {
+	return http.HandlerFunc(func(resp http.ResponseWriter,
+		req *http.Request) {
+		sc := &serverCodec{resp: resp, req: req}
+
+		err := srv.ServeRequest(sc)
+		if err != nil && !sc.responded {
+			resp.Header().Set("Content-Type", "text/xml")
+			writeFault(resp, errNotWellFormed,
+				fmt.Sprintf("Unmarshal error: %v", err))
+		}
+	})
+}
@@ -0,0 +1,160 @@
+package xmlrpc
+
+import (
+	"errors"
+	"net/http/httptest"
+	"net/rpc"
+	"testing"
+)
+
+type ArithArgs struct {
+	A, B int
+}
+
+type ArithQuotient struct {
+	Quo int `xmlrpc:"quotient"`
+	Rem int `xmlrpc:"remainder"`
+}
+
+type Arith int
+
+func (t *Arith) Multiply(args *ArithArgs, reply *int) error {
+	*reply = args.A * args.B
+	return nil
+}
+
+func (t *Arith) Divide(args *ArithArgs, quo *ArithQuotient) error {
+	if args.B == 0 {
+		return errors.New("divide by zero")
+	}
+
+	quo.Quo = args.A / args.B
+	quo.Rem = args.A % args.B
+	return nil
+}
+
+// start an XML-RPC server for the net/rpc Arith service
+func newArithServer(t *testing.T) *httptest.Server {
+	srv := rpc.NewServer()
+	if err := srv.Register(new(Arith)); err != nil {
+		t.Fatalf("Cannot register Arith: %v", err)
+	}
+
+	return httptest.NewServer(NewRPCHandler(srv))
+}
+
+func TestRPCServerCodec(t *testing.T) {
+	srvr := newArithServer(t)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	val, err, fault := client.RPCCall("Arith.Multiply",
+		map[string]interface{}{"a": 6, "b": 7})
+	if err != nil || fault != nil {
+		t.Fatalf("Multiply returned error %v, fault %v", err, fault)
+	} else if val != 42 {
+		t.Fatalf("Multiply returned %v, not 42", val)
+	}
+
+	val, err, fault = client.RPCCall("Arith.Divide", &ArithArgs{17, 5})
+	if err != nil || fault != nil {
+		t.Fatalf("Divide returned error %v, fault %v", err, fault)
+	}
+
+	var quo ArithQuotient
+	if err = Decode(val, &quo); err != nil {
+		t.Fatalf("Cannot decode %v: %v", val, err)
+	} else if quo.Quo != 3 || quo.Rem != 2 {
+		t.Fatalf("Divide returned %+v", quo)
+	}
+
+	faults := []struct {
+		methodName string
+		args       []interface{}
+		code       int
+	}{
+		{"Arith.Divide", []interface{}{&ArithArgs{1, 0}}, errApplication},
+		{"Arith.Missing", []interface{}{&ArithArgs{}}, errUnknownMethod},
+		{"Arith.Multiply", []interface{}{"abc"}, errInvalidParams},
+		{"Arith.Multiply", []interface{}{1, 2}, errInvalidParams},
+	}
+
+	for _, f := range faults {
+		_, err, fault = client.RPCCall(f.methodName, f.args...)
+		if err != nil {
+			t.Fatalf("%s%v returned error %v", f.methodName, f.args, err)
+		} else if fault == nil || fault.Code != f.code {
+			t.Fatalf("%s%v returned fault %v, not code %d", f.methodName,
+				f.args, fault, f.code)
+		}
+	}
+}
+
+func TestRPCClientCodec(t *testing.T) {
+	// net/rpc client talking to an XML-RPC Handler
+	h := NewHandler()
+	h.RegisterFunc("Arith.Multiply", func(args ArithArgs) int {
+		return args.A * args.B
+	}, false)
+
+	client, done := newTestClient(t, h)
+	defer done()
+
+	rpcClient := NewRPCClient(client)
+	defer rpcClient.Close()
+
+	var product int
+	err := rpcClient.Call("Arith.Multiply", &ArithArgs{6, 7}, &product)
+	if err != nil {
+		t.Fatalf("Multiply failed: %v", err)
+	} else if product != 42 {
+		t.Fatalf("Multiply returned %d, not 42", product)
+	}
+
+	err = rpcClient.Call("Arith.Missing", &ArithArgs{}, &product)
+	if _, ok := err.(rpc.ServerError); !ok {
+		t.Fatalf("Missing returned %T error %v", err, err)
+	}
+}
+
+func TestRPCCodecRoundTrip(t *testing.T) {
+	// net/rpc client talking to a net/rpc server over XML-RPC
+	srvr := newArithServer(t)
+	defer srvr.Close()
+
+	client, err := NewClientURL(srvr.URL)
+	if err != nil {
+		t.Fatalf("NewClientURL failed: %v", err)
+	}
+
+	rpcClient := NewRPCClient(client)
+	defer rpcClient.Close()
+
+	calls := make([]*rpc.Call, 10)
+	for i := range calls {
+		calls[i] = rpcClient.Go("Arith.Divide", &ArithArgs{100, i + 1},
+			new(ArithQuotient), nil)
+	}
+
+	for i, call := range calls {
+		<-call.Done
+		if call.Error != nil {
+			t.Fatalf("Divide #%d failed: %v", i, call.Error)
+		}
+
+		quo := call.Reply.(*ArithQuotient)
+		if quo.Quo != 100/(i+1) || quo.Rem != 100%(i+1) {
+			t.Fatalf("Divide #%d returned %+v", i, quo)
+		}
+	}
+
+	var quo ArithQuotient
+	err = rpcClient.Call("Arith.Divide", &ArithArgs{1, 0}, &quo)
+	if err == nil || err.Error() != "divide by zero (code#-32500)" {
+		t.Fatalf("Divide by zero returned %v", err)
+	}
+}
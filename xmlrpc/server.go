@@ -2,42 +2,182 @@ package xmlrpc
 
 import (
 	"bytes"
+	"context"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
 type methodData struct {
-	obj       interface{}
-	method    reflect.Method
+	name      string
+	fn        reflect.Value
 	padParams bool
+
+	// 1 if the first argument is a context.Context, 0 otherwise
+	argOffset int
+}
+
+// cached context.Context reflect.Type value
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// create the data for a procedure, noting whether the function expects
+// the request's context as its first argument
+func newMethodData(name string, fn reflect.Value,
+	padParams bool) *methodData {
+	md := &methodData{name: name, fn: fn, padParams: padParams}
+
+	ftype := fn.Type()
+	if ftype.NumIn() > 0 && ftype.In(0) == contextType {
+		md.argOffset = 1
+	}
+
+	return md
 }
 
+// map from procedure names (and aliases) to the procedure data
+type methodMap map[string]*methodData
+
 // Map from XML-RPC procedure names to Go methods
+//
+// Procedures can be registered, unregistered and replaced while the
+// handler is serving requests.  Every change builds a new copy of the map,
+// so a request always sees a consistent snapshot without taking a lock.
 type Handler struct {
-	methods map[string]*methodData
+	// current methodMap snapshot
+	methods atomic.Value
+
+	// current []Interceptor snapshot
+	interceptors atomic.Value
+
+	// serializes changes to the method map and the settings below
+	mutex sync.Mutex
+
+	// if true, add a lower-cased alias for every registered name
+	caseInsensitive bool
+
+	// smallest response which is compressed (negative to disable) and
+	// limit on the decompressed size of a request body
+	compressMin int
+	maxRequest  int64
 }
 
 // create a new handler mapping XML-RPC procedure names to Go methods
 func NewHandler() *Handler {
 	h := new(Handler)
-	h.methods = make(map[string]*methodData)
+	h.methods.Store(make(methodMap))
+	h.caseInsensitive = true
+	h.compressMin = defaultCompressMin
+	h.maxRequest = DefaultMaxBodySize
 	return h
 }
 
-// register all methods associated with the Go object, passing them
-// through the name mapper if one is supplied
+// control whether subsequent registrations also add a lower-cased alias
+// for each name (the default) so that "GetSize" can be called as "getsize"
+func (h *Handler) SetCaseInsensitive(on bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.caseInsensitive = on
+}
+
+// return the current method map snapshot, which must not be modified
+func (h *Handler) methodMap() methodMap {
+	return h.methods.Load().(methodMap)
+}
+
+// find the procedure registered as 'name'
+func (h *Handler) lookup(name string) (*methodData, bool) {
+	md, ok := h.methodMap()[name]
+	return md, ok
+}
+
+// return a private copy of the current method map
+func (h *Handler) copyMethods() methodMap {
+	old := h.methodMap()
+
+	mmap := make(methodMap, len(old))
+	for key, md := range old {
+		mmap[key] = md
+	}
+
+	return mmap
+}
+
+// remove all names and aliases for the procedure from the map
+func (mmap methodMap) remove(md *methodData) {
+	for key, val := range mmap {
+		if val == md {
+			delete(mmap, key)
+		}
+	}
+}
+
+// return the keys under which the procedure 'name' is stored
+func (h *Handler) methodKeys(name string) []string {
+	lname := strings.ToLower(name)
+	if !h.caseInsensitive || lname == name {
+		return []string{name}
+	}
+
+	return []string{name, lname}
+}
+
+// add a list of procedures, failing without changing anything if a name
+// or alias is used more than once in the list
 //
-// The name mapper can return "" to ignore a method or transform the
-// name as desired
-func (h *Handler) Register(obj interface{}, mapper func(string) string,
-	padParams bool) error {
+// If 'replace' is false, it's also an error for a name to be registered
+// already, otherwise the old procedure (with all its aliases) is removed
+func (h *Handler) addMethods(mlist []*methodData, replace bool) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	mmap := h.copyMethods()
+
+	added := make(map[string]*methodData)
+	for _, md := range mlist {
+		for _, key := range h.methodKeys(md.name) {
+			if prev, ok := added[key]; ok && prev != md {
+				return fmt.Errorf("Method \"%s\" collides with \"%s\"",
+					md.name, prev.name)
+			} else if prev, ok := mmap[key]; ok {
+				if !replace {
+					return fmt.Errorf("Method \"%s\" collides with"+
+						" registered method \"%s\"", md.name, prev.name)
+				}
+
+				mmap.remove(prev)
+			}
+
+			added[key] = md
+		}
+	}
+
+	for key, md := range added {
+		mmap[key] = md
+	}
+
+	h.methods.Store(mmap)
+
+	return nil
+}
+
+// build the list of procedures for all methods associated with the object
+func objectMethods(obj interface{}, mapper func(string) string,
+	padParams bool) ([]*methodData, error) {
 	ot := reflect.TypeOf(obj)
+	if ot == nil {
+		return nil, errors.New("Cannot register nil object")
+	}
 
+	var mlist []*methodData
 	for i := 0; i < ot.NumMethod(); i++ {
 		m := ot.Method(i)
 		if m.PkgPath != "" {
@@ -54,11 +194,144 @@ func (h *Handler) Register(obj interface{}, mapper func(string) string,
 			}
 		}
 
-		md := &methodData{obj: obj, method: m, padParams: padParams}
-		h.methods[name] = md
-		h.methods[strings.ToLower(name)] = md
+		md := newMethodData(name, reflect.ValueOf(obj).Method(i),
+			padParams)
+		mlist = append(mlist, md)
+	}
+
+	return mlist, nil
+}
+
+// build the procedure data for a standalone function
+func funcMethod(name string, fn interface{},
+	padParams bool) (*methodData, error) {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		return nil, fmt.Errorf("Cannot register %T as \"%s\";"+
+			" not a function", fn, name)
+	} else if name == "" {
+		return nil, errors.New("Cannot register function without a name")
+	}
+
+	return newMethodData(name, fv, padParams), nil
+}
+
+// register all methods associated with the Go object, passing them
+// through the name mapper if one is supplied
+//
+// The name mapper can return "" to ignore a method or transform the
+// name as desired.  An error is returned (and no methods are added) if
+// any of the resulting names collides with a registered name.
+func (h *Handler) Register(obj interface{}, mapper func(string) string,
+	padParams bool) error {
+	mlist, err := objectMethods(obj, mapper, padParams)
+	if err != nil {
+		return err
+	}
+
+	return h.addMethods(mlist, false)
+}
+
+// register all methods associated with the Go object under 'namespace',
+// so that method "GetPost" registered with namespace "blog" becomes
+// "blog.getPost"
+func (h *Handler) RegisterName(namespace string, obj interface{},
+	padParams bool) error {
+	if namespace == "" {
+		return h.Register(obj, LowerCamelMapper, padParams)
+	}
+
+	mapper := ChainMappers(LowerCamelMapper, PrefixMapper(namespace+"."))
+	return h.Register(obj, mapper, padParams)
+}
+
+// register a standalone function or closure as the XML-RPC procedure
+// 'name'
+//
+// Arguments, padding and return values are handled exactly as they are
+// for methods added by Register
+func (h *Handler) RegisterFunc(name string, fn interface{},
+	padParams bool) error {
+	md, err := funcMethod(name, fn, padParams)
+	if err != nil {
+		return err
+	}
+
+	return h.addMethods([]*methodData{md}, false)
+}
+
+// register all methods associated with the Go object like Register,
+// replacing any procedures which are already registered under the same
+// names
+func (h *Handler) Replace(obj interface{}, mapper func(string) string,
+	padParams bool) error {
+	mlist, err := objectMethods(obj, mapper, padParams)
+	if err != nil {
+		return err
+	}
+
+	return h.addMethods(mlist, true)
+}
+
+// register a standalone function like RegisterFunc, replacing any
+// procedure which is already registered as 'name'
+func (h *Handler) ReplaceFunc(name string, fn interface{},
+	padParams bool) error {
+	md, err := funcMethod(name, fn, padParams)
+	if err != nil {
+		return err
+	}
+
+	return h.addMethods([]*methodData{md}, true)
+}
+
+// control whether missing trailing parameters are zero-filled for the
+// procedure registered as 'name', overriding the padParams setting used
+// when it was registered
+func (h *Handler) SetPadParams(name string, padParams bool) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	mmap := h.copyMethods()
+
+	old, ok := mmap[name]
+	if !ok {
+		return fmt.Errorf("Method \"%s\" is not registered", name)
+	}
+
+	// requests may be using the old data, so replace it with a copy
+	md := *old
+	md.padParams = padParams
+	for key, val := range mmap {
+		if val == old {
+			mmap[key] = &md
+		}
+	}
+
+	h.methods.Store(mmap)
+
+	return nil
+}
+
+// remove the named procedures (along with their aliases) from the handler
+//
+// Nothing is removed if any of the names is not registered
+func (h *Handler) Unregister(names ...string) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	mmap := h.copyMethods()
+	for _, name := range names {
+		md, ok := mmap[name]
+		if !ok {
+			return fmt.Errorf("Method \"%s\" is not registered", name)
+		}
+
+		mmap.remove(md)
 	}
 
+	h.methods.Store(mmap)
+
 	return nil
 }
 
@@ -95,87 +368,240 @@ func writeFault(out io.Writer, code int, msg string) {
 
 // semi-standard XML-RPC response codes
 const (
-	errNotWellFormed = -32700
-	errUnknownMethod = -32601
-	errInvalidParams = -32602
-	errInternal      = -32603
+	errNotWellFormed  = -32700
+	errInvalidRequest = -32600
+	errUnknownMethod  = -32601
+	errInvalidParams  = -32602
+	errInternal       = -32603
 )
 
-// handle an XML-RPC request
-func (h *Handler) handleRequest(resp http.ResponseWriter, req *http.Request) {
-	methodName, params, err, fault := Unmarshal(req.Body)
+// return the number of fixed (non-context, non-variadic) arguments
+func (md *methodData) numFixed() int {
+	ftype := md.fn.Type()
 
-	if err != nil {
-		writeFault(resp, errNotWellFormed,
-			fmt.Sprintf("Unmarshal error: %v", err))
-		return
-	} else if fault != nil {
-		writeFault(resp, fault.Code, fault.Msg)
-		return
+	numFixed := ftype.NumIn() - md.argOffset
+	if ftype.IsVariadic() {
+		numFixed--
 	}
 
-	var args []interface{}
-	var ok bool
+	return numFixed
+}
 
-	if args, ok = params.([]interface{}); !ok {
-		args := make([]interface{}, 1, 1)
-		args[0] = params
+// return the minimum number of parameters needed to call the procedure
+//
+// Trailing pointer arguments are optional and are passed as nil when
+// omitted, and all arguments are optional for padded procedures
+func (md *methodData) minParams() int {
+	if md.padParams {
+		return 0
 	}
 
-	var mData *methodData
+	ftype := md.fn.Type()
 
-	if mData, ok = h.methods[methodName]; !ok {
-		writeFault(resp, errUnknownMethod,
-			fmt.Sprintf("Unknown method \"%s\"", methodName))
-		return
+	numFixed := md.numFixed()
+	for numFixed > 0 &&
+		ftype.In(md.argOffset+numFixed-1).Kind() == reflect.Ptr {
+		numFixed--
 	}
 
-	expArgs := mData.method.Type.NumIn()
-	if len(args)+1 != expArgs {
-		if !mData.padParams || len(args)+1 > expArgs {
-			writeFault(resp, errInvalidParams,
-				fmt.Sprintf("Bad number of parameters for method \"%s\","+
-					" (%d != %d)", methodName, len(args), expArgs-1))
-			return
+	return numFixed
+}
+
+// convert the XML-RPC parameters into the procedure's arguments
+//
+// Missing optional arguments are zero-filled and any parameters beyond the
+// fixed arguments are passed to the final '...T' argument of variadic
+// procedures
+func (md *methodData) callArgs(ctx context.Context, methodName string,
+	args []interface{}) ([]reflect.Value, *Fault) {
+	ftype := md.fn.Type()
+	numFixed := md.numFixed()
+
+	if len(args) < md.minParams() ||
+		(!ftype.IsVariadic() && len(args) > numFixed) {
+		var expStr string
+		if minArgs := md.minParams(); ftype.IsVariadic() {
+			expStr = fmt.Sprintf("at least %d", minArgs)
+		} else if minArgs < numFixed {
+			expStr = fmt.Sprintf("%d-%d", minArgs, numFixed)
+		} else {
+			expStr = fmt.Sprintf("%d", numFixed)
 		}
+
+		return nil, NewFault(errInvalidParams,
+			fmt.Sprintf("Bad number of parameters for method \"%s\","+
+				" (%d, expected %s)", methodName, len(args), expStr))
 	}
 
-	vals := make([]reflect.Value, expArgs, expArgs)
+	var vals []reflect.Value
+	if md.argOffset > 0 {
+		vals = append(vals, reflect.ValueOf(&ctx).Elem())
+	}
 
-	vals[0] = reflect.ValueOf(mData.obj)
-	for i := 1; i < expArgs; i++ {
-		if mData.padParams && i >= len(args) {
-			vals[i] = reflect.Zero(mData.method.Type.In(i))
+	for i := 0; i < numFixed; i++ {
+		argType := ftype.In(md.argOffset + i)
+		if i >= len(args) {
+			vals = append(vals, reflect.Zero(argType))
 			continue
 		}
 
-		if !reflect.TypeOf(args[i-1]).ConvertibleTo(mData.method.Type.In(i)) {
-			writeFault(resp, errInvalidParams,
-				fmt.Sprintf("Bad %s argument #%d (%v should be %v)",
-					methodName, i-1, reflect.TypeOf(args[i-1]),
-					mData.method.Type.In(i)))
-			return
+		val, err := convertValue(args[i], argType,
+			fmt.Sprintf("params[%d]", i))
+		if err != nil {
+			return nil, NewFault(errInvalidParams,
+				fmt.Sprintf("Bad %s argument: %v", methodName, err))
 		}
 
-		vals[i] = reflect.ValueOf(args[i-1])
+		vals = append(vals, val)
 	}
 
-	rtnVals := mData.method.Func.Call(vals)
+	if ftype.IsVariadic() {
+		elemType := ftype.In(ftype.NumIn() - 1).Elem()
+		for i := numFixed; i < len(args); i++ {
+			val, err := convertValue(args[i], elemType,
+				fmt.Sprintf("params[%d]", i))
+			if err != nil {
+				return nil, NewFault(errInvalidParams,
+					fmt.Sprintf("Bad %s argument: %v", methodName, err))
+			}
 
-	if len(rtnVals) == 1 && reflect.TypeOf(rtnVals[0].Interface()) == faultType {
-		if fault, ok := rtnVals[0].Interface().(*Fault); ok {
-			writeFault(resp, fault.Code, fault.Msg)
-			return
+			vals = append(vals, val)
 		}
 	}
 
-	mArray := make([]interface{}, len(rtnVals), len(rtnVals))
+	return vals, nil
+}
+
+// Invoker runs the XML-RPC procedure 'methodName' with the decoded
+// parameters, returning either the values to send back to the client or
+// a fault
+type Invoker func(ctx context.Context, methodName string,
+	params []interface{}) ([]interface{}, *Fault)
+
+// An Interceptor wraps the dispatch of every request made to a Handler.
+// It can examine or rewrite the method name, parameters and results,
+// and can return a fault without calling 'next' to reject the request.
+type Interceptor func(ctx context.Context, methodName string,
+	params []interface{}, next Invoker) ([]interface{}, *Fault)
+
+// add interceptors to the handler
+//
+// Interceptors run in the order they were added, so the first one added
+// sees the request first and the result last.  They run before the method
+// name is looked up, so they also see requests for unknown methods.
+func (h *Handler) Use(interceptors ...Interceptor) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	var ilist []Interceptor
+	if old, ok := h.interceptors.Load().([]Interceptor); ok {
+		ilist = append(ilist, old...)
+	}
+	ilist = append(ilist, interceptors...)
+
+	h.interceptors.Store(ilist)
+}
+
+// return the sorted names of the registered procedures (without their
+// lower-cased aliases) along with "system.listMethods"
+func (h *Handler) listMethods() []string {
+	names := map[string]bool{listMethodsName: true}
+	for _, md := range h.methodMap() {
+		names[md.name] = true
+	}
+
+	list := make([]string, 0, len(names))
+	for name := range names {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+
+	return list
+}
+
+// look up and call an XML-RPC procedure
+//
+// "system.listMethods" is answered by the handler unless a procedure has
+// been registered under that name
+func (h *Handler) invoke(ctx context.Context, methodName string,
+	params []interface{}) ([]interface{}, *Fault) {
+	mData, ok := h.lookup(methodName)
+	if !ok && methodName == listMethodsName {
+		if len(params) != 0 {
+			return nil, NewFault(errInvalidParams,
+				fmt.Sprintf("%s takes no parameters", listMethodsName))
+		}
+
+		return []interface{}{h.listMethods()}, nil
+	} else if !ok {
+		return nil, NewFault(errUnknownMethod,
+			fmt.Sprintf("Unknown method \"%s\"", methodName))
+	}
+
+	vals, fault := mData.callArgs(ctx, methodName, params)
+	if fault != nil {
+		return nil, fault
+	}
+
+	rtnVals := mData.fn.Call(vals)
+
+	if len(rtnVals) == 1 && rtnVals[0].Type() == faultType {
+		if fault := rtnVals[0].Interface().(*Fault); fault != nil {
+			return nil, fault
+		}
+
+		rtnVals = rtnVals[:0]
+	}
+
+	results := make([]interface{}, len(rtnVals), len(rtnVals))
 	for i := 0; i < len(rtnVals); i++ {
-		mArray[i] = rtnVals[i].Interface()
+		results[i] = rtnVals[i].Interface()
+	}
+
+	return results, nil
+}
+
+// run a request through the interceptors and then the procedure
+func (h *Handler) dispatch(ctx context.Context, methodName string,
+	params []interface{}) ([]interface{}, *Fault) {
+	ilist, _ := h.interceptors.Load().([]Interceptor)
+
+	next := Invoker(h.invoke)
+	for i := len(ilist) - 1; i >= 0; i-- {
+		icpt, inner := ilist[i], next
+		next = func(ctx context.Context, methodName string,
+			params []interface{}) ([]interface{}, *Fault) {
+			return icpt(ctx, methodName, params, inner)
+		}
+	}
+
+	return next(ctx, methodName, params)
+}
+
+// handle an XML-RPC request
+func (h *Handler) handleRequest(resp http.ResponseWriter, req *http.Request) {
+	resp.Header().Set("Content-Type", "text/xml")
+
+	methodName, args, err, fault := unmarshalCharset(req.Body,
+		contentCharset(req.Header.Get("Content-Type")))
+
+	if err != nil {
+		writeFault(resp, errNotWellFormed,
+			fmt.Sprintf("Unmarshal error: %v", err))
+		return
+	} else if fault != nil {
+		writeFault(resp, fault.Code, fault.Msg)
+		return
+	}
+
+	results, fault := h.dispatch(req.Context(), methodName, args)
+	if fault != nil {
+		writeFault(resp, fault.Code, fault.Msg)
+		return
 	}
 
 	buf := bytes.NewBufferString("")
-	err = marshalArray(buf, "", mArray)
+	err = marshalArray(buf, "", results)
 	if err != nil {
 		writeFault(resp, errInternal, fmt.Sprintf("Failed to marshal %s: %v",
 			methodName, err))
@@ -185,10 +611,47 @@ func (h *Handler) handleRequest(resp http.ResponseWriter, req *http.Request) {
 	buf.WriteTo(resp)
 }
 
+// handle an XML-RPC request sent over HTTP, or a JSON-RPC 2.0 request if
+// the Content-Type is "application/json"
+//
+// Request bodies may be compressed with gzip or deflate, and responses
+// are compressed if the client accepts it (see SetCompression).
+func (h *Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	compressMin, maxRequest := h.compressionSettings()
+
+	encoding := req.Header.Get("Content-Encoding")
+	body, err := decodeBody(req.Body, encoding, maxRequest)
+	if err != nil {
+		status := http.StatusBadRequest
+		if normalizeEncoding(encoding) == "" {
+			status = http.StatusUnsupportedMediaType
+		}
+
+		http.Error(resp, err.Error(), status)
+		return
+	}
+	req.Body = body
+
+	// only buffer the response when the client will accept it compressed
+	respEnc := chooseEncoding(req.Header.Get("Accept-Encoding"))
+	if respEnc != "" {
+		bresp := &bufferedResponse{ResponseWriter: resp}
+		defer bresp.finish(respEnc, compressMin)
+		resp = bresp
+	}
+
+	if isJSONContentType(req.Header.Get("Content-Type")) {
+		h.handleJSONRequest(resp, req)
+		return
+	}
+
+	h.handleRequest(resp, req)
+}
+
 // start an XML-RPC server
 func StartServer(port int) *Handler {
 	h := NewHandler()
-	http.HandleFunc("/", h.handleRequest)
+	http.Handle("/", h)
 	go http.ListenAndServe(fmt.Sprintf(":%d", port), nil)
 	return h
 }
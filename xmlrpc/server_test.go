@@ -0,0 +1,589 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// send an XML-RPC request directly to the handler and decode the response
+func callHandler(t *testing.T, h *Handler, methodName string,
+	args ...interface{}) (interface{}, *Fault) {
+	buf := bytes.NewBufferString("")
+	if err := marshalArray(buf, methodName, args); err != nil {
+		t.Fatalf("Cannot marshal %s request: %v", methodName, err)
+	}
+
+	return postHandler(t, h, buf.String())
+}
+
+// send a raw XML request directly to the handler and decode the response
+func postHandler(t *testing.T, h *Handler, xmlStr string) (interface{},
+	*Fault) {
+	req, err := http.NewRequest("POST", "/RPC2", strings.NewReader(xmlStr))
+	if err != nil {
+		t.Fatalf("Cannot create request: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	h.handleRequest(rec, req)
+
+	_, val, err, fault := Unmarshal(rec.Body)
+	if err != nil {
+		t.Fatalf("Cannot unmarshal response: %v", err)
+	}
+
+	return val, fault
+}
+
+type sizer struct {
+	size int
+}
+
+func (s *sizer) GetSize() int           { return s.size }
+func (s *sizer) SetSize(size int)       { s.size = size }
+func (s *sizer) Fail(msg string) *Fault { return NewFault(17, msg) }
+
+func TestRegisterMethods(t *testing.T) {
+	obj := &sizer{}
+
+	h := NewHandler()
+	if err := h.Register(obj, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if _, fault := callHandler(t, h, "SetSize", 42); fault != nil {
+		t.Fatalf("SetSize returned fault %v", fault)
+	}
+
+	val, fault := callHandler(t, h, "getsize")
+	if fault != nil {
+		t.Fatalf("getsize returned fault %v", fault)
+	} else if val != 42 {
+		t.Fatalf("getsize returned %v, not 42", val)
+	}
+
+	_, fault = callHandler(t, h, "Fail", "oops")
+	if fault == nil || fault.Code != 17 || fault.Msg != "oops" {
+		t.Fatalf("Fail returned unexpected fault %v", fault)
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	total := 0
+
+	h := NewHandler()
+	err := h.RegisterFunc("Adder.Add", func(a, b int) int {
+		total += a + b
+		return total
+	}, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	callHandler(t, h, "adder.add", 1, 2)
+	val, fault := callHandler(t, h, "Adder.Add", 3, 4)
+	if fault != nil {
+		t.Fatalf("Adder.Add returned fault %v", fault)
+	} else if val != 10 {
+		t.Fatalf("Adder.Add returned %v, not 10", val)
+	}
+
+	if _, fault = callHandler(t, h, "adder.add", 1); fault == nil {
+		t.Fatal("adder.add accepted a missing parameter")
+	} else if fault.Code != errInvalidParams {
+		t.Fatalf("Unexpected fault %v for missing parameter", fault)
+	}
+}
+
+func TestRegisterFuncPadParams(t *testing.T) {
+	h := NewHandler()
+	err := h.RegisterFunc("join", func(a, b string) string {
+		return a + "|" + b
+	}, true)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	val, fault := callHandler(t, h, "join", "x")
+	if fault != nil {
+		t.Fatalf("join returned fault %v", fault)
+	} else if val != "x|" {
+		t.Fatalf("join returned %v, not \"x|\"", val)
+	}
+}
+
+func TestRegisterFuncMultipleReturns(t *testing.T) {
+	h := NewHandler()
+	err := h.RegisterFunc("pair", func(f float64) (float64, bool) {
+		return f * 2, f > 1
+	}, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	val, fault := callHandler(t, h, "pair", 1)
+	if fault != nil {
+		t.Fatalf("pair returned fault %v", fault)
+	}
+
+	expVal := []interface{}{2.0, false}
+	if !reflect.DeepEqual(val, expVal) {
+		t.Fatalf("pair returned %v, not %v", val, expVal)
+	}
+}
+
+func TestRegisterFuncBad(t *testing.T) {
+	h := NewHandler()
+
+	if err := h.RegisterFunc("notFunc", 123, false); err == nil {
+		t.Fatal("RegisterFunc accepted a non-function")
+	} else if !strings.Contains(err.Error(), "not a function") {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	var nilFunc func()
+	if err := h.RegisterFunc("nilFunc", nilFunc, false); err == nil {
+		t.Fatal("RegisterFunc accepted a nil function")
+	}
+
+	if err := h.RegisterFunc("", func() {}, false); err == nil {
+		t.Fatal("RegisterFunc accepted an empty name")
+	}
+}
+
+func TestUnregister(t *testing.T) {
+	h := NewHandler()
+	if err := h.Register(&sizer{}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := h.Unregister("GetSize", "setsize"); err != nil {
+		t.Fatalf("Unregister failed: %v", err)
+	}
+
+	for _, name := range []string{"GetSize", "getsize", "SetSize",
+		"setsize"} {
+		if _, fault := callHandler(t, h, name); fault == nil {
+			t.Fatalf("Unregistered method %s is still callable", name)
+		} else if fault.Code != errUnknownMethod {
+			t.Fatalf("Unexpected fault %v for %s", fault, name)
+		}
+	}
+
+	if err := h.Unregister("Fail", "GetSize"); err == nil {
+		t.Fatal("Unregister did not fail for unknown method")
+	}
+	if _, fault := callHandler(t, h, "Fail", "x"); fault.Code != 17 {
+		t.Fatalf("Failed Unregister removed \"Fail\" (%v)", fault)
+	}
+
+	// the name can be reused after it's been unregistered
+	err := h.RegisterFunc("GetSize", func() int { return 99 }, false)
+	if err != nil {
+		t.Fatalf("Cannot reuse unregistered name: %v", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	h := NewHandler()
+	if err := h.Register(&sizer{size: 1}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if err := h.Replace(&sizer{size: 2}, nil, false); err != nil {
+		t.Fatalf("Replace failed: %v", err)
+	}
+
+	if val, _ := callHandler(t, h, "getsize"); val != 2 {
+		t.Fatalf("Replaced getsize returned %v, not 2", val)
+	}
+
+	err := h.ReplaceFunc("GetSize", func() int { return 3 }, false)
+	if err != nil {
+		t.Fatalf("ReplaceFunc failed: %v", err)
+	}
+
+	for _, name := range []string{"GetSize", "getsize"} {
+		if val, _ := callHandler(t, h, name); val != 3 {
+			t.Fatalf("Replaced %s returned %v, not 3", name, val)
+		}
+	}
+}
+
+func TestListMethods(t *testing.T) {
+	h := NewHandler()
+	if err := h.Register(&sizer{}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	// lower-cased aliases aren't listed
+	val, fault := callHandler(t, h, "system.listMethods")
+	if fault != nil {
+		t.Fatalf("system.listMethods returned %v", fault)
+	}
+
+	expNames := []interface{}{"Fail", "GetSize", "SetSize",
+		"system.listMethods"}
+	if !reflect.DeepEqual(val, expNames) {
+		t.Fatalf("system.listMethods returned %v, not %v", val, expNames)
+	}
+
+	if _, fault = callHandler(t, h, "system.listMethods", 1); fault == nil ||
+		fault.Code != errInvalidParams {
+		t.Fatalf("system.listMethods with a parameter returned %v", fault)
+	}
+
+	// a registered procedure takes precedence
+	err := h.RegisterFunc("system.listMethods", func() []string {
+		return []string{"secret"}
+	}, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	val, _ = callHandler(t, h, "system.listMethods")
+	if !reflect.DeepEqual(val, []interface{}{"secret"}) {
+		t.Fatalf("Registered system.listMethods returned %v", val)
+	}
+}
+
+func TestRegisterWhileServing(t *testing.T) {
+	h := NewHandler()
+	err := h.RegisterFunc("ping", func() int { return 1 }, false)
+	if err != nil {
+		t.Fatalf("RegisterFunc failed: %v", err)
+	}
+
+	client, done := newTestClient(t, h)
+	defer done()
+
+	const numCalls = 50
+
+	errs := make(chan error)
+	for i := 0; i < 4; i++ {
+		go func() {
+			for j := 0; j < numCalls; j++ {
+				val, err, fault := client.RPCCall("ping")
+				if err != nil {
+					errs <- err
+					return
+				} else if fault != nil {
+					errs <- fmt.Errorf("ping returned fault %v", fault)
+					return
+				} else if val != 1 && val != 2 {
+					errs <- fmt.Errorf("ping returned %v", val)
+					return
+				}
+			}
+			errs <- nil
+		}()
+	}
+
+	go func() {
+		for j := 0; j < numCalls; j++ {
+			name := fmt.Sprintf("plugin%d", j)
+			if err := h.RegisterFunc(name, func() {}, false); err != nil {
+				errs <- err
+				return
+			}
+			if err := h.Unregister(name); err != nil {
+				errs <- err
+				return
+			}
+			if err := h.ReplaceFunc("ping", func() int { return 2 },
+				false); err != nil {
+				errs <- err
+				return
+			}
+		}
+		errs <- nil
+	}()
+
+	for i := 0; i < 5; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("Concurrent call failed: %v", err)
+		}
+	}
+}
+
+type coercePoint struct {
+	X     int
+	Y     int
+	Label string `xmlrpc:"name"`
+}
+
+func TestArgumentCoercion(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("join", func(words []string, sep string) string {
+		return strings.Join(words, sep)
+	}, false)
+	h.RegisterFunc("double", func(i int) int { return i * 2 }, false)
+	h.RegisterFunc("point", func(p coercePoint) string {
+		return fmt.Sprintf("%s(%d,%d)", p.Label, p.X, p.Y)
+	}, false)
+	h.RegisterFunc("ptr", func(p *coercePoint) bool { return p == nil },
+		false)
+	h.RegisterFunc("length", func(data []byte) int { return len(data) },
+		false)
+
+	val, fault := callHandler(t, h, "join", []string{"a", "b", "c"}, "-")
+	if fault != nil {
+		t.Fatalf("join returned fault %v", fault)
+	} else if val != "a-b-c" {
+		t.Fatalf("join returned %v, not \"a-b-c\"", val)
+	}
+
+	if val, fault = callHandler(t, h, "double", "21"); fault != nil {
+		t.Fatalf("double returned fault %v", fault)
+	} else if val != 42 {
+		t.Fatalf("double returned %v, not 42", val)
+	}
+
+	val, fault = postHandler(t, h, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>point</methodName>
+  <params>
+    <param>
+      <value><struct>
+        <member><name>x</name><value><int>1</int></value></member>
+        <member><name>Y</name><value><string>2</string></value></member>
+        <member><name>name</name><value>pt</value></member>
+      </struct></value>
+    </param>
+  </params>
+</methodCall>`)
+	if fault != nil {
+		t.Fatalf("point returned fault %v", fault)
+	} else if val != "pt(1,2)" {
+		t.Fatalf("point returned %v, not \"pt(1,2)\"", val)
+	}
+
+	if val, fault = callHandler(t, h, "ptr", nil); fault != nil {
+		t.Fatalf("ptr returned fault %v", fault)
+	} else if val != true {
+		t.Fatalf("ptr(nil) returned %v", val)
+	}
+
+	if val, fault = callHandler(t, h, "length", []byte("abcd")); fault != nil {
+		t.Fatalf("length returned fault %v", fault)
+	} else if val != 4 {
+		t.Fatalf("length returned %v, not 4", val)
+	}
+}
+
+func TestArgumentCoercionFault(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("sum", func(nums []int) int { return len(nums) }, false)
+
+	_, fault := callHandler(t, h, "sum", []interface{}{1, 2, "three"})
+	if fault == nil {
+		t.Fatal("sum accepted a non-numeric string")
+	} else if fault.Code != errInvalidParams {
+		t.Fatalf("Unexpected fault code %d", fault.Code)
+	} else if !strings.Contains(fault.Msg, "params[0][2]") {
+		t.Fatalf("Fault \"%s\" does not name the bad value", fault.Msg)
+	}
+}
+
+func TestVariadicParams(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("sum", func(base int, nums ...int) int {
+		for _, n := range nums {
+			base += n
+		}
+		return base
+	}, false)
+
+	tests := []struct {
+		args   []interface{}
+		expVal int
+	}{
+		{[]interface{}{1}, 1},
+		{[]interface{}{1, 2}, 3},
+		{[]interface{}{1, 2, "3", 4}, 10},
+	}
+
+	for _, tst := range tests {
+		val, fault := callHandler(t, h, "sum", tst.args...)
+		if fault != nil {
+			t.Fatalf("sum%v returned fault %v", tst.args, fault)
+		} else if val != tst.expVal {
+			t.Fatalf("sum%v returned %v, not %v", tst.args, val, tst.expVal)
+		}
+	}
+
+	if _, fault := callHandler(t, h, "sum"); fault == nil {
+		t.Fatal("sum accepted a missing fixed parameter")
+	} else if !strings.Contains(fault.Msg, "at least 1") {
+		t.Fatalf("Unexpected fault %v", fault)
+	}
+
+	_, fault := callHandler(t, h, "sum", 1, 2, "x")
+	if fault == nil || !strings.Contains(fault.Msg, "params[2]") {
+		t.Fatalf("Bad variadic parameter returned fault %v", fault)
+	}
+}
+
+func TestOptionalPointerParams(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("greet", func(name string, greeting *string,
+		count *int) string {
+		msg := "hello"
+		if greeting != nil {
+			msg = *greeting
+		}
+		if count != nil {
+			msg = strings.Repeat(msg, *count)
+		}
+		return msg + " " + name
+	}, false)
+
+	tests := []struct {
+		args   []interface{}
+		expVal string
+	}{
+		{[]interface{}{"bob"}, "hello bob"},
+		{[]interface{}{"bob", "hi"}, "hi bob"},
+		{[]interface{}{"bob", "hi", 2}, "hihi bob"},
+	}
+
+	for _, tst := range tests {
+		val, fault := callHandler(t, h, "greet", tst.args...)
+		if fault != nil {
+			t.Fatalf("greet%v returned fault %v", tst.args, fault)
+		} else if val != tst.expVal {
+			t.Fatalf("greet%v returned %v, not %v", tst.args, val,
+				tst.expVal)
+		}
+	}
+
+	if _, fault := callHandler(t, h, "greet"); fault == nil {
+		t.Fatal("greet accepted a missing required parameter")
+	} else if !strings.Contains(fault.Msg, "expected 1-3") {
+		t.Fatalf("Unexpected fault %v", fault)
+	}
+}
+
+func TestSetPadParams(t *testing.T) {
+	h := NewHandler()
+	if err := h.Register(&sizer{}, nil, false); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	if _, fault := callHandler(t, h, "SetSize"); fault == nil {
+		t.Fatal("SetSize accepted a missing parameter")
+	}
+
+	if err := h.SetPadParams("setsize", true); err != nil {
+		t.Fatalf("SetPadParams failed: %v", err)
+	}
+
+	for _, name := range []string{"SetSize", "setsize"} {
+		if _, fault := callHandler(t, h, name); fault != nil {
+			t.Fatalf("Padded %s returned fault %v", name, fault)
+		}
+	}
+
+	// other methods registered with the same object are unchanged
+	if _, fault := callHandler(t, h, "Fail"); fault.Code != errInvalidParams {
+		t.Fatalf("Fail returned unexpected fault %v", fault)
+	}
+
+	if err := h.SetPadParams("unknown", true); err == nil {
+		t.Fatal("SetPadParams accepted an unknown method")
+	}
+}
+
+type userKey struct{}
+
+func TestInterceptors(t *testing.T) {
+	h := NewHandler()
+	h.RegisterFunc("whoami", func(ctx context.Context) string {
+		return ctx.Value(userKey{}).(string)
+	}, false)
+	h.RegisterFunc("add", func(a, b int) int { return a + b }, false)
+
+	var calls []string
+	h.Use(func(ctx context.Context, methodName string,
+		params []interface{}, next Invoker) ([]interface{}, *Fault) {
+		calls = append(calls, "log:"+methodName)
+		results, fault := next(ctx, methodName, params)
+		calls = append(calls, "done:"+methodName)
+		return results, fault
+	})
+	h.Use(func(ctx context.Context, methodName string,
+		params []interface{}, next Invoker) ([]interface{}, *Fault) {
+		if methodName == "secret" {
+			return nil, NewFault(403, "Forbidden")
+		}
+
+		ctx = context.WithValue(ctx, userKey{}, "alice")
+		return next(ctx, methodName, params)
+	})
+	h.Use(func(ctx context.Context, methodName string,
+		params []interface{}, next Invoker) ([]interface{}, *Fault) {
+		if methodName != "add" {
+			return next(ctx, methodName, params)
+		}
+
+		// double the second parameter and the result
+		params[1] = params[1].(int) * 2
+		results, fault := next(ctx, methodName, params)
+		if fault == nil {
+			results[0] = results[0].(int) * 2
+		}
+		return results, fault
+	})
+
+	if val, fault := callHandler(t, h, "whoami"); fault != nil {
+		t.Fatalf("whoami returned fault %v", fault)
+	} else if val != "alice" {
+		t.Fatalf("whoami returned %v, not \"alice\"", val)
+	}
+
+	if val, fault := callHandler(t, h, "add", 1, 2); fault != nil {
+		t.Fatalf("add returned fault %v", fault)
+	} else if val != 10 {
+		t.Fatalf("add returned %v, not 10", val)
+	}
+
+	_, fault := callHandler(t, h, "secret")
+	if fault == nil || fault.Code != 403 {
+		t.Fatalf("secret returned fault %v, not Forbidden", fault)
+	}
+
+	expCalls := []string{"log:whoami", "done:whoami", "log:add", "done:add",
+		"log:secret", "done:secret"}
+	if !reflect.DeepEqual(calls, expCalls) {
+		t.Fatalf("Interceptors saw %v, not %v", calls, expCalls)
+	}
+}
+
+func TestInterceptorUnknownMethod(t *testing.T) {
+	h := NewHandler()
+	h.Use(func(ctx context.Context, methodName string,
+		params []interface{}, next Invoker) ([]interface{}, *Fault) {
+		if methodName == "virtual" {
+			return []interface{}{len(params)}, nil
+		}
+
+		return next(ctx, methodName, params)
+	})
+
+	if val, fault := callHandler(t, h, "virtual", 1, 2, 3); fault != nil {
+		t.Fatalf("virtual returned fault %v", fault)
+	} else if val != 3 {
+		t.Fatalf("virtual returned %v, not 3", val)
+	}
+
+	_, fault := callHandler(t, h, "missing")
+	if fault == nil || fault.Code != errUnknownMethod {
+		t.Fatalf("missing returned fault %v", fault)
+	}
+}
@@ -37,6 +37,7 @@ const (
 	tokenData
 	tokenDateTime
 	tokenDouble
+	tokenI8
 	tokenInt
 	tokenNil
 	tokenString
@@ -46,8 +47,9 @@ const (
 // map token strings to constant values
 var tokenMap map[string]int
 
-// load the tokens into the token map
-func initTokenMap() {
+// load the tokens into the token map before any goroutine can parse
+// a document
+func init() {
 	tokenMap = make(map[string]int)
 	tokenMap["array"] = tokenArray
 	tokenMap["base64"] = tokenBase64
@@ -56,6 +58,7 @@ func initTokenMap() {
 	tokenMap["dateTime.iso8601"] = tokenDateTime
 	tokenMap["double"] = tokenDouble
 	tokenMap["fault"] = tokenFault
+	tokenMap["i8"] = tokenI8
 	tokenMap["int"] = tokenInt
 	tokenMap["member"] = tokenMember
 	tokenMap["methodCall"] = tokenMethodCall
@@ -153,10 +156,6 @@ func getNextToken(p *xml.Decoder) (*xmlToken, error) {
 		return nil, err
 	}
 
-	if tokenMap == nil {
-		initTokenMap()
-	}
-
 	switch v := tag.(type) {
 	case xml.StartElement:
 		tok, err := getTagToken(v.Name.Local)
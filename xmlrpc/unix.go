@@ -0,0 +1,178 @@
+package xmlrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// host name used in the URL of a client created with a "unix" URL
+const unixHost = "unix"
+
+// open every connection with 'dial' (for example to tunnel requests
+// through an SSH connection) instead of dialing the host in the URL;
+// the client still speaks HTTP over the connection
+//
+// The dialer is installed in the client's HTTP transport after all the
+// other options have run, so it can be combined with WithHTTPClient in
+// any order.  It can't be used with a transport which isn't an
+// *http.Transport.
+func WithDialer(dial func(ctx context.Context, network,
+	addr string) (net.Conn, error)) ClientOption {
+	return func(c *Client) error {
+		if dial == nil {
+			return errors.New("Nil dialer")
+		}
+
+		c.dial = dial
+		return nil
+	}
+}
+
+// return a dialer which connects to the Unix domain socket at 'path'
+func unixDialer(path string) func(ctx context.Context, network,
+	addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn,
+		error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// connect to the server through the Unix domain socket at 'path'
+// (as used by Supervisor and rTorrent); the host in the client's URL is
+// ignored but the path is still sent in each request
+func WithUnixSocket(path string) ClientOption {
+	return func(c *Client) error {
+		if path == "" {
+			return errors.New("No Unix socket path")
+		}
+
+		c.dial = unixDialer(path)
+		return nil
+	}
+}
+
+// return true if a server is accepting connections on the Unix socket
+func unixSocketInUse(path string) bool {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return false
+	}
+
+	conn.Close()
+	return true
+}
+
+// a listener on a Unix domain socket which removes the socket file when
+// it's closed
+type unixListener struct {
+	net.Listener
+	path string
+	once sync.Once
+}
+
+// stop listening and remove the socket file
+func (l *unixListener) Close() error {
+	err := l.Listener.Close()
+	l.once.Do(func() { os.Remove(l.path) })
+	return err
+}
+
+// create a directory in 'parent' which is only accessible by its owner;
+// the name is kept short since socket paths are limited to about 100
+// bytes
+func mkdirPrivate(parent string) (string, error) {
+	for i := 0; i < 100; i++ {
+		dir := filepath.Join(parent, fmt.Sprintf(".%04x",
+			rand.Intn(0x10000)))
+		if err := os.Mkdir(dir, 0700); err == nil {
+			return dir, nil
+		} else if !os.IsExist(err) {
+			return "", err
+		}
+	}
+
+	return "", fmt.Errorf("Cannot create a private directory in %s",
+		parent)
+}
+
+// listen on the Unix domain socket at 'path', giving the socket file the
+// permissions in 'perm' (for example 0600 so only the owner can connect)
+//
+// The socket is created in a private directory next to 'path' and only
+// renamed into place once its permissions are set, so there's no moment
+// when other users can connect to it.  A socket left behind by a server
+// which is no longer running is removed, but an error is returned if the
+// socket is in use or 'path' is some other kind of file.  The socket file
+// is removed when the listener is closed.
+//
+// Socket paths are limited to 107 bytes on Linux and 103 on most other
+// systems.  The temporary socket's path is the directory holding 'path'
+// plus 8 bytes ("/.1a2b/s"), so that directory's path must be no longer
+// than 99 bytes on Linux, even if 'path' itself would fit.
+func ListenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		} else if unixSocketInUse(path) {
+			return nil, fmt.Errorf("Socket %s is already in use", path)
+		} else if err = os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	dir, err := mkdirPrivate(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	tmpPath := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", tmpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// the name changes, so the listener can't remove the file itself
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	if err = os.Chmod(tmpPath, perm); err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		l.Close()
+		return nil, err
+	}
+
+	return &unixListener{Listener: l, path: path}, nil
+}
+
+// serve XML-RPC requests on connections accepted by the listener, which
+// can be any net.Listener (TCP, Unix domain socket, etc.), until it is
+// closed
+func (h *Handler) Serve(l net.Listener) error {
+	return http.Serve(l, h)
+}
+
+// start an XML-RPC server on the Unix domain socket at 'path', which is
+// given the permissions in 'perm'; close the returned listener to stop
+// the server and remove the socket
+func StartUnixServer(path string, perm os.FileMode) (*Handler,
+	net.Listener, error) {
+	l, err := ListenUnix(path, perm)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := NewHandler()
+	go h.Serve(l)
+	return h, l, nil
+}
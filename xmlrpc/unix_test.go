@@ -0,0 +1,264 @@
+package xmlrpc
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// return a socket path in a new temporary directory (short enough for
+// the Unix socket path limit)
+func tempSocket(t *testing.T) string {
+	dir, err := os.MkdirTemp("", "xmlrpc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return filepath.Join(dir, "rpc.sock")
+}
+
+func TestUnixSocketServer(t *testing.T) {
+	path := tempSocket(t)
+
+	h, l, err := StartUnixServer(path, 0600)
+	if err != nil {
+		t.Fatalf("StartUnixServer failed: %v", err)
+	}
+	defer l.Close()
+
+	h.RegisterFunc("add", func(a, b int) int { return a + b }, false)
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	} else if fi.Mode()&os.ModeSocket == 0 {
+		t.Fatalf("%s is not a socket (mode %v)", path, fi.Mode())
+	} else if fi.Mode().Perm() != 0600 {
+		t.Fatalf("Socket permissions are %v, not 0600", fi.Mode().Perm())
+	}
+
+	// the private directory the socket was created in is gone
+	if entries, err := os.ReadDir(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 1 {
+		t.Fatalf("Directory holds %d files, not just the socket",
+			len(entries))
+	}
+
+	for _, rawURL := range []string{"unix://" + path,
+		"http://ignored/RPC2"} {
+		client, err := NewClientURL(rawURL, WithUnixSocket(path))
+		if err != nil {
+			t.Fatalf("%s: %v", rawURL, err)
+		}
+
+		if val, err, fault := client.RPCCall("add", 2, 3); err != nil ||
+			fault != nil {
+			t.Fatalf("%s: call failed: %v, %v", rawURL, err, fault)
+		} else if val != 5 {
+			t.Fatalf("%s: returned %v", rawURL, val)
+		}
+	}
+
+	if _, err = NewClientURL("unix://"); err == nil {
+		t.Fatal("NewClientURL accepted a unix URL with no path")
+	}
+
+	l.Close()
+	if _, err = os.Lstat(path); !os.IsNotExist(err) {
+		t.Fatalf("Socket was not removed: %v", err)
+	}
+}
+
+func TestUnixSocketTransportOptions(t *testing.T) {
+	path := tempSocket(t)
+
+	h, l, err := StartUnixServer(path, 0600)
+	if err != nil {
+		t.Fatalf("StartUnixServer failed: %v", err)
+	}
+	defer l.Close()
+
+	h.RegisterFunc("ping", func() string { return "pong" }, false)
+
+	// the socket is still used when a later option replaces the transport
+	var tests = []struct {
+		rawURL string
+		opts   []ClientOption
+	}{
+		{"unix://" + path,
+			[]ClientOption{WithHTTPClient(&http.Client{
+				Timeout: time.Minute})}},
+		{"unix://" + path,
+			[]ClientOption{WithHTTPClient(&http.Client{
+				Transport: &http.Transport{}})}},
+		{"http://ignored/RPC2",
+			[]ClientOption{WithUnixSocket(path),
+				WithHTTPClient(&http.Client{})}},
+	}
+
+	for _, test := range tests {
+		client, err := NewClientURL(test.rawURL, test.opts...)
+		if err != nil {
+			t.Fatalf("NewClientURL failed: %v", err)
+		}
+
+		if val, err, fault := client.RPCCall("ping"); err != nil ||
+			fault != nil {
+			t.Fatalf("Call failed: %v, %v", err, fault)
+		} else if val != "pong" {
+			t.Fatalf("Returned %v", val)
+		}
+	}
+
+	// a transport which can't dial the socket is an error
+	_, err = NewClientURL("unix://"+path, WithTransport(NewRecorder("", nil)))
+	if err == nil {
+		t.Fatal("NewClientURL accepted a transport which ignores the socket")
+	}
+}
+
+func TestListenUnixStaleSocket(t *testing.T) {
+	path := tempSocket(t)
+
+	// leave a socket behind, as a crashed server would
+	old, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.(*net.UnixListener).SetUnlinkOnClose(false)
+
+	// a socket which is still in use must not be replaced
+	if _, err = ListenUnix(path, 0600); err == nil {
+		t.Fatal("ListenUnix replaced a socket in use")
+	}
+
+	old.Close()
+
+	l, err := ListenUnix(path, 0660)
+	if err != nil {
+		t.Fatalf("ListenUnix did not replace a stale socket: %v", err)
+	}
+	defer l.Close()
+
+	if fi, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if fi.Mode().Perm() != 0660 {
+		t.Fatalf("Socket permissions are %v, not 0660", fi.Mode().Perm())
+	}
+
+	// other files are never removed
+	file := filepath.Join(filepath.Dir(path), "file")
+	if err = os.WriteFile(file, []byte("data"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ListenUnix(file, 0600); err == nil {
+		t.Fatal("ListenUnix replaced a regular file")
+	} else if _, err = os.Stat(file); err != nil {
+		t.Fatalf("Regular file was removed: %v", err)
+	}
+}
+
+// return a path of exactly 'size' bytes for a socket named "s" in a new
+// temporary directory
+func longSocketPath(t *testing.T, size int) string {
+	dir := t.TempDir()
+	pad := size - len(dir) - len("/x/s") + 1
+	if pad < 1 {
+		t.Skipf("Temporary directory %s is too long", dir)
+	}
+
+	dir = filepath.Join(dir, strings.Repeat("x", pad))
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	return filepath.Join(dir, "s")
+}
+
+func TestListenUnixLongPath(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Socket path limit is only known for Linux")
+	}
+
+	// the directory can be up to 99 bytes long
+	for _, size := range []int{92, 101} {
+		path := longSocketPath(t, size)
+		if len(path) != size {
+			t.Fatalf("Path %s is %d bytes, not %d", path, len(path), size)
+		}
+
+		l, err := ListenUnix(path, 0600)
+		if err != nil {
+			t.Fatalf("ListenUnix failed for a %d byte path: %v", size, err)
+		}
+		l.Close()
+	}
+
+	path := longSocketPath(t, 102)
+	if l, err := ListenUnix(path, 0600); err == nil {
+		l.Close()
+		t.Fatal("ListenUnix accepted a path in a 100 byte directory")
+	} else if entries, err := os.ReadDir(filepath.Dir(path)); err != nil {
+		t.Fatal(err)
+	} else if len(entries) != 0 {
+		t.Fatalf("ListenUnix left %s behind", entries[0].Name())
+	}
+}
+
+func TestClientDialer(t *testing.T) {
+	path := tempSocket(t)
+
+	l, err := ListenUnix(path, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	h := NewHandler()
+	h.RegisterFunc("ping", func() string { return "pong" }, false)
+	go h.Serve(l)
+
+	var dials int32
+	client, err := NewClientURL("http://example.invalid/RPC2",
+		WithDialer(func(ctx context.Context, network,
+			addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			if addr != "example.invalid:80" {
+				t.Errorf("Dialer called for %s %s", network, addr)
+			}
+
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if val, err, fault := client.RPCCall("ping"); err != nil ||
+			fault != nil {
+			t.Fatalf("Call failed: %v, %v", err, fault)
+		} else if val != "pong" {
+			t.Fatalf("Returned %v", val)
+		}
+	}
+
+	// connections are reused
+	if n := atomic.LoadInt32(&dials); n != 1 {
+		t.Fatalf("Dialer was called %d times", n)
+	}
+
+	if _, err = NewClientURL("http://host/", WithDialer(nil)); err == nil {
+		t.Fatal("WithDialer accepted a nil dialer")
+	}
+}
@@ -0,0 +1,521 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A Document is converted to JSON as an object holding "methodName" and
+// "params" for a <methodCall>, or "params" or "fault" for a
+// <methodResponse>.  Values are converted as follows so that no type
+// information is lost:
+//
+//	<int>, <i4>           integer, e.g. 42
+//	<double>              number with a fraction or exponent, e.g. 42.0
+//	<boolean>             true or false
+//	<string>              string
+//	<array>               array
+//	<struct>              object, with the members in document order
+//	<i8>                  {"$i8": 42}
+//	<dateTime.iso8601>    {"$dateTime.iso8601": "19980717T14:08:55"}
+//	<base64>              {"$base64": "aGVsbG8="}
+//	<nil/>                {"$nil": true}
+//
+// A struct with a single member whose name starts with "$" is wrapped in
+// {"$struct": {...}} so it isn't mistaken for one of the tagged values.
+// JSON null is also accepted as <nil/>.
+//
+// These are the tags for values which have no natural JSON form.
+const (
+	jsonTagI8       = "$i8"
+	jsonTagDateTime = "$dateTime.iso8601"
+	jsonTagBase64   = "$base64"
+	jsonTagNil      = "$nil"
+	jsonTagStruct   = "$struct"
+)
+
+// write a JSON string without escaping '<', '>' and '&'
+func writeJSONString(buf *bytes.Buffer, s string) {
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+
+	// drop the newline added by Encode
+	buf.Truncate(buf.Len() - 1)
+}
+
+// write a tagged JSON object
+func writeJSONTagged(buf *bytes.Buffer, tag string, val string) {
+	buf.WriteByte('{')
+	writeJSONString(buf, tag)
+	buf.WriteString(": ")
+	buf.WriteString(val)
+	buf.WriteByte('}')
+}
+
+// return the JSON form of a <double>, which always includes a fraction or
+// exponent so it isn't read back as an <int>
+func jsonDouble(text string) (string, error) {
+	if json.Valid([]byte(text)) && strings.ContainsAny(text, ".eE") {
+		return text, nil
+	}
+
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return "", fmt.Errorf("Bad <double> value \"%s\"", text)
+	} else if math.IsInf(f, 0) || math.IsNaN(f) {
+		return "", fmt.Errorf("Cannot convert <double> %s to JSON", text)
+	}
+
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eE") {
+		s += ".0"
+	}
+
+	return s, nil
+}
+
+// write the JSON form of a struct's members
+func writeJSONMembers(buf *bytes.Buffer, members []*Member) error {
+	buf.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		writeJSONString(buf, m.Name)
+		buf.WriteString(": ")
+		if err := m.Value.writeJSON(buf); err != nil {
+			return err
+		}
+	}
+	buf.WriteByte('}')
+
+	return nil
+}
+
+// write the JSON form of the value
+func (v *Value) writeJSON(buf *bytes.Buffer) error {
+	switch v.Kind {
+	case KindString:
+		writeJSONString(buf, v.Text)
+	case KindInt:
+		if _, err := strconv.ParseInt(v.Text, 10, 64); err != nil {
+			return fmt.Errorf("Bad <int> value \"%s\"", v.Text)
+		}
+		buf.WriteString(v.Text)
+	case KindI8:
+		if _, err := strconv.ParseInt(v.Text, 10, 64); err != nil {
+			return fmt.Errorf("Bad <i8> value \"%s\"", v.Text)
+		}
+		writeJSONTagged(buf, jsonTagI8, v.Text)
+	case KindBoolean:
+		if v.Text == "1" {
+			buf.WriteString("true")
+		} else if v.Text == "0" {
+			buf.WriteString("false")
+		} else {
+			return fmt.Errorf("Bad <boolean> value \"%s\"", v.Text)
+		}
+	case KindDouble:
+		s, err := jsonDouble(v.Text)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(s)
+	case KindDateTime:
+		var tbuf bytes.Buffer
+		writeJSONString(&tbuf, v.Text)
+		writeJSONTagged(buf, jsonTagDateTime, tbuf.String())
+	case KindBase64:
+		writeJSONTagged(buf, jsonTagBase64,
+			"\""+base64.StdEncoding.EncodeToString(v.Bytes)+"\"")
+	case KindNil:
+		writeJSONTagged(buf, jsonTagNil, "true")
+	case KindArray:
+		buf.WriteByte('[')
+		for i, elem := range v.Array {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+
+			if err := elem.writeJSON(buf); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case KindStruct:
+		if len(v.Members) == 1 && strings.HasPrefix(v.Members[0].Name, "$") {
+			buf.WriteByte('{')
+			writeJSONString(buf, jsonTagStruct)
+			buf.WriteString(": ")
+			if err := writeJSONMembers(buf, v.Members); err != nil {
+				return err
+			}
+			buf.WriteByte('}')
+		} else if err := writeJSONMembers(buf, v.Members); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown value kind %v", v.Kind)
+	}
+
+	return nil
+}
+
+// return the JSON form of the value
+func (v *Value) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := v.writeJSON(&buf); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// return the JSON form of the document
+func (d *Document) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	if !d.Response {
+		writeJSONString(&buf, "methodName")
+		buf.WriteString(": ")
+		writeJSONString(&buf, d.MethodName)
+		buf.WriteString(", ")
+	}
+
+	if d.Fault != nil {
+		buf.WriteString("\"fault\": ")
+		if err := d.Fault.writeJSON(&buf); err != nil {
+			return nil, err
+		}
+	} else {
+		buf.WriteString("\"params\": [")
+		for i, param := range d.Params {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+
+			if err := param.writeJSON(&buf); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte(']')
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// JSON document parser which keeps the order of object members
+type jsonParser struct {
+	dec *json.Decoder
+}
+
+// create a parser for the JSON data
+func newJSONParser(r io.Reader) *jsonParser {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return &jsonParser{dec: dec}
+}
+
+// read the next token, which must be the delimiter 'delim'
+func (jp *jsonParser) expectDelim(delim json.Delim) error {
+	tok, err := jp.dec.Token()
+	if err != nil {
+		return err
+	} else if d, ok := tok.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("Expected '%v', not %v", delim, tok)
+	}
+
+	return nil
+}
+
+// read the remaining members of an object, along with the closing '}'
+func (jp *jsonParser) members() ([]*Member, error) {
+	members := []*Member{}
+	for jp.dec.More() {
+		tok, err := jp.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		name, ok := tok.(string)
+		if !ok {
+			return nil, fmt.Errorf("Bad object key %v", tok)
+		}
+
+		val, err := jp.value()
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &Member{Name: name, Value: val})
+	}
+
+	return members, jp.expectDelim('}')
+}
+
+// read an object whose '{' has been read, which is either a struct or a
+// tagged value
+func (jp *jsonParser) object() (*Value, error) {
+	if !jp.dec.More() {
+		return &Value{Kind: KindStruct, Members: []*Member{}},
+			jp.expectDelim('}')
+	}
+
+	tok, err := jp.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	name, ok := tok.(string)
+	if !ok {
+		return nil, fmt.Errorf("Bad object key %v", tok)
+	}
+
+	if name == jsonTagStruct {
+		// the wrapped struct's member names are never tags
+		if err = jp.expectDelim('{'); err != nil {
+			return nil, err
+		}
+
+		members, err := jp.members()
+		if err != nil {
+			return nil, err
+		}
+
+		return &Value{Kind: KindStruct, Members: members},
+			jp.expectDelim('}')
+	}
+
+	val, err := jp.value()
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := jp.members()
+	if err != nil {
+		return nil, err
+	}
+
+	members = append([]*Member{{Name: name, Value: val}}, members...)
+	if len(members) == 1 && strings.HasPrefix(name, "$") {
+		return untagJSONValue(members[0])
+	}
+
+	return &Value{Kind: KindStruct, Members: members}, nil
+}
+
+// convert a tagged object to the value it represents
+func untagJSONValue(m *Member) (*Value, error) {
+	val := m.Value
+
+	switch m.Name {
+	case jsonTagI8:
+		if val.Kind == KindInt {
+			return &Value{Kind: KindI8, Text: val.Text}, nil
+		} else if val.Kind == KindString {
+			i, err := strconv.ParseInt(strings.TrimSpace(val.Text), 10, 64)
+			if err == nil {
+				return &Value{Kind: KindI8, Text: strconv.FormatInt(i, 10)},
+					nil
+			}
+		}
+	case jsonTagDateTime:
+		if val.Kind == KindString {
+			return &Value{Kind: KindDateTime, Text: val.Text}, nil
+		}
+	case jsonTagBase64:
+		if val.Kind == KindString {
+			data, err := decodeBase64(val.Text)
+			if err != nil {
+				return nil, err
+			}
+
+			return &Value{Kind: KindBase64, Bytes: data}, nil
+		}
+	case jsonTagNil:
+		return &Value{Kind: KindNil}, nil
+	default:
+		return nil, fmt.Errorf("Unknown JSON tag \"%s\"", m.Name)
+	}
+
+	return nil, fmt.Errorf("Bad value for JSON tag \"%s\"", m.Name)
+}
+
+// read a JSON value
+func (jp *jsonParser) value() (*Value, error) {
+	tok, err := jp.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch t := tok.(type) {
+	case nil:
+		return &Value{Kind: KindNil}, nil
+	case bool:
+		if t {
+			return &Value{Kind: KindBoolean, Text: "1"}, nil
+		}
+		return &Value{Kind: KindBoolean, Text: "0"}, nil
+	case string:
+		return &Value{Kind: KindString, Text: t}, nil
+	case json.Number:
+		s := t.String()
+		if strings.ContainsAny(s, ".eE") {
+			return &Value{Kind: KindDouble, Text: s}, nil
+		}
+
+		if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+			return nil, fmt.Errorf("Integer %s is out of range", s)
+		}
+		return &Value{Kind: KindInt, Text: s}, nil
+	case json.Delim:
+		if t == '[' {
+			val := &Value{Kind: KindArray, Array: []*Value{}}
+			for jp.dec.More() {
+				elem, err := jp.value()
+				if err != nil {
+					return nil, err
+				}
+
+				val.Array = append(val.Array, elem)
+			}
+
+			return val, jp.expectDelim(']')
+		} else if t == '{' {
+			return jp.object()
+		}
+	}
+
+	return nil, fmt.Errorf("Unexpected JSON token %v", tok)
+}
+
+// read a JSON document
+func (jp *jsonParser) document() (*Document, error) {
+	if err := jp.expectDelim('{'); err != nil {
+		return nil, err
+	}
+
+	doc := &Document{Response: true}
+	for jp.dec.More() {
+		tok, err := jp.dec.Token()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tok {
+		case "methodName":
+			if tok, err = jp.dec.Token(); err != nil {
+				return nil, err
+			}
+
+			name, ok := tok.(string)
+			if !ok {
+				return nil, fmt.Errorf("Bad method name %v", tok)
+			}
+
+			doc.Response = false
+			doc.MethodName = name
+		case "params":
+			if err = jp.expectDelim('['); err != nil {
+				return nil, err
+			}
+
+			doc.Params = []*Value{}
+			for jp.dec.More() {
+				val, err := jp.value()
+				if err != nil {
+					return nil, err
+				}
+
+				doc.Params = append(doc.Params, val)
+			}
+
+			if err = jp.expectDelim(']'); err != nil {
+				return nil, err
+			}
+		case "fault":
+			if doc.Fault, err = jp.value(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("Unknown document key %v", tok)
+		}
+	}
+
+	if err := jp.expectDelim('}'); err != nil {
+		return nil, err
+	}
+
+	if doc.Fault != nil && (!doc.Response || doc.Params != nil) {
+		return nil, errors.New("Only a response without params can" +
+			" have a fault")
+	} else if doc.Response && doc.Fault == nil && doc.Params == nil {
+		return nil, errors.New("Response has neither params nor fault")
+	}
+
+	return doc, nil
+}
+
+// set the value from its JSON form
+func (v *Value) UnmarshalJSON(data []byte) error {
+	val, err := newJSONParser(bytes.NewReader(data)).value()
+	if err != nil {
+		return err
+	}
+
+	*v = *val
+	return nil
+}
+
+// set the document from its JSON form
+func (d *Document) UnmarshalJSON(data []byte) error {
+	doc, err := newJSONParser(bytes.NewReader(data)).document()
+	if err != nil {
+		return err
+	}
+
+	*d = *doc
+	return nil
+}
+
+// read a document in its JSON form
+func ParseJSONDocument(r io.Reader) (*Document, error) {
+	return newJSONParser(r).document()
+}
+
+// convert an XML-RPC document read from 'r' to JSON
+func XMLToJSON(w io.Writer, r io.Reader) error {
+	doc, err := ParseDocument(r)
+	if err != nil {
+		return err
+	}
+
+	data, err := doc.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// convert a JSON document read from 'r' to XML-RPC
+func JSONToXML(w io.Writer, r io.Reader) error {
+	doc, err := ParseJSONDocument(r)
+	if err != nil {
+		return err
+	}
+
+	return doc.WriteXML(w)
+}
@@ -0,0 +1,205 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const typedCallXML = `<?xml version="1.0"?>
+<methodCall>
+  <methodName>examples.everything</methodName>
+  <params>
+    <param><value><i4>41</i4></value></param>
+    <param><value><int> 42 </int></value></param>
+    <param><value><i8>9007199254740993</i8></value></param>
+    <param><value><double>1.50</double></value></param>
+    <param><value><double>2</double></value></param>
+    <param><value><boolean>1</boolean></value></param>
+    <param><value><string>a &lt;b&gt; &amp; c</string></value></param>
+    <param><value>untyped</value></param>
+    <param><value></value></param>
+    <param><value><dateTime.iso8601>19980717T14:08:55</dateTime.iso8601></value></param>
+    <param><value><base64>aGVs
+bG8</base64></value></param>
+    <param><value><nil/></value></param>
+    <param><value><array><data>
+      <value><int>1</int></value>
+      <value><string>two</string></value>
+    </data></array></value></param>
+    <param><value><struct>
+      <member><name>z</name><value><int>1</int></value></member>
+      <member><name>a</name><value><array><data></data></array></value></member>
+    </struct></value></param>
+    <param><value><struct>
+      <member><name>$base64</name><value>not a tag</value></member>
+    </struct></value></param>
+  </params>
+</methodCall>`
+
+const typedCallJSON = `{"methodName": "examples.everything", "params": [` +
+	`41, 42, {"$i8": 9007199254740993}, 1.50, 2.0, true, ` +
+	`"a <b> & c", "untyped", "", ` +
+	`{"$dateTime.iso8601": "19980717T14:08:55"}, ` +
+	`{"$base64": "aGVsbG8="}, {"$nil": true}, [1, "two"], ` +
+	`{"z": 1, "a": []}, {"$struct": {"$base64": "not a tag"}}]}`
+
+func TestXMLToJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := XMLToJSON(&buf, strings.NewReader(typedCallXML)); err != nil {
+		t.Fatalf("XMLToJSON failed: %v", err)
+	}
+
+	if got := strings.TrimSpace(buf.String()); got != typedCallJSON {
+		t.Fatalf("XMLToJSON returned\n%s\nnot\n%s", got, typedCallJSON)
+	}
+}
+
+// convert a document to JSON, failing the test on any error
+func docJSON(t *testing.T, doc *Document) string {
+	data, err := doc.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	return string(data)
+}
+
+func TestDocumentRoundTrip(t *testing.T) {
+	docs := []string{
+		typedCallXML,
+		`<methodResponse><params><param><value><struct>
+			<member><name>ok</name><value><boolean>0</boolean></value></member>
+		</struct></value></param></params></methodResponse>`,
+		`<methodResponse><fault><value><struct>
+			<member><name>faultCode</name><value><int>4</int></value></member>
+			<member><name>faultString</name><value>Too many</value></member>
+		</struct></value></fault></methodResponse>`,
+		`<methodCall><methodName>noParams</methodName></methodCall>`,
+	}
+
+	for _, xmlStr := range docs {
+		doc, err := ParseDocumentString(xmlStr)
+		if err != nil {
+			t.Fatalf("Cannot parse %s: %v", xmlStr, err)
+		}
+
+		jsonStr := docJSON(t, doc)
+
+		// JSON -> Document -> JSON should be unchanged
+		jdoc, err := ParseJSONDocument(strings.NewReader(jsonStr))
+		if err != nil {
+			t.Fatalf("Cannot parse JSON %s: %v", jsonStr, err)
+		} else if got := docJSON(t, jdoc); got != jsonStr {
+			t.Fatalf("JSON round trip returned\n%s\nnot\n%s", got, jsonStr)
+		}
+
+		// XML -> Document -> XML -> Document should be unchanged
+		var buf bytes.Buffer
+		if err = jdoc.WriteXML(&buf); err != nil {
+			t.Fatalf("WriteXML failed: %v", err)
+		}
+
+		xdoc, err := ParseDocument(&buf)
+		if err != nil {
+			t.Fatalf("Cannot parse written XML: %v", err)
+		} else if got := docJSON(t, xdoc); got != jsonStr {
+			t.Fatalf("XML round trip returned\n%s\nnot\n%s", got, jsonStr)
+		}
+	}
+}
+
+func TestDocumentReadableByUnmarshal(t *testing.T) {
+	doc, err := ParseDocumentString(typedCallXML)
+	if err != nil {
+		t.Fatalf("ParseDocument failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err = doc.WriteXML(&buf); err != nil {
+		t.Fatalf("WriteXML failed: %v", err)
+	}
+
+	methodName, params, err, fault := unmarshalParams(&buf)
+	if err != nil || fault != nil {
+		t.Fatalf("Unmarshal failed: %v, %v", err, fault)
+	} else if methodName != "examples.everything" {
+		t.Fatalf("Method name is \"%s\"", methodName)
+	} else if len(params) != len(doc.Params) {
+		t.Fatalf("Got %d params, not %d", len(params), len(doc.Params))
+	}
+
+	if params[2] != int64(9007199254740993) {
+		t.Errorf("<i8> was decoded as %v<%T>", params[2], params[2])
+	}
+	if params[6] != "a <b> & c" {
+		t.Errorf("<string> was decoded as \"%v\"", params[6])
+	}
+	if data, ok := params[10].([]byte); !ok || string(data) != "hello" {
+		t.Errorf("<base64> was decoded as %v", params[10])
+	}
+}
+
+func TestJSONToXMLValues(t *testing.T) {
+	jsonStr := `{"methodName": "m", "params": [null, 1e3, -7,
+		{"$i8": "12"}, {"$nil": null}, {}]}`
+
+	doc, err := ParseJSONDocument(strings.NewReader(jsonStr))
+	if err != nil {
+		t.Fatalf("ParseJSONDocument failed: %v", err)
+	}
+
+	kinds := []ValueKind{KindNil, KindDouble, KindInt, KindI8, KindNil,
+		KindStruct}
+	if len(doc.Params) != len(kinds) {
+		t.Fatalf("Got %d params, not %d", len(doc.Params), len(kinds))
+	}
+
+	for i, kind := range kinds {
+		if doc.Params[i].Kind != kind {
+			t.Errorf("Param #%d is %v, not %v", i, doc.Params[i].Kind, kind)
+		}
+	}
+}
+
+func TestDocumentErrors(t *testing.T) {
+	badXML := []string{
+		``,
+		`<params></params>`,
+		`<methodCall><params></params></methodCall>`,
+		`<methodResponse></methodResponse>`,
+		`<methodResponse><params><param><value><int>x</int></value>` +
+			`</param></params></methodResponse>`,
+		`<methodResponse><params><param><value><boolean>2</boolean>` +
+			`</value></param></params></methodResponse>`,
+		`<methodResponse><params><param><value><int>1</int>` +
+			`<int>2</int></value></param></params></methodResponse>`,
+		`<methodCall><methodName>m</methodName><fault><value>x</value>` +
+			`</fault></methodCall>`,
+	}
+
+	for _, xmlStr := range badXML {
+		if _, err := ParseDocumentString(xmlStr); err == nil {
+			t.Errorf("ParseDocument accepted %s", xmlStr)
+		}
+	}
+
+	badJSON := []string{
+		`[]`,
+		`{}`,
+		`{"methodName": 1, "params": []}`,
+		`{"methodName": "m", "fault": 1}`,
+		`{"params": [], "fault": 1}`,
+		`{"params": [{"$bogus": 1}]}`,
+		`{"params": [{"$base64": "!!!"}]}`,
+		`{"params": [99999999999999999999]}`,
+		`{"params": [1], "extra": 2}`,
+	}
+
+	for _, jsonStr := range badJSON {
+		_, err := ParseJSONDocument(strings.NewReader(jsonStr))
+		if err == nil {
+			t.Errorf("ParseJSONDocument accepted %s", jsonStr)
+		}
+	}
+}
@@ -1,14 +1,13 @@
 package xmlrpc
 
 import (
-	"bytes"
+	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
-	"net/http"
-	"net/url"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -33,6 +32,12 @@ func (f *Fault) String() string {
 	return fmt.Sprintf("%s (code#%d)", f.Msg, f.Code)
 }
 
+// Return the XML-RPC fault as an error message, so a *Fault can be used
+// as an error
+func (f *Fault) Error() string {
+	return f.String()
+}
+
 func extractParams(v []interface{}) interface{} {
 	if len(v) == 0 {
 		return nil
@@ -50,10 +55,10 @@ func getMethodName(p *xml.Decoder) (string, error) {
 	inName := false
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
-			return "", errors.New("Unexpected end-of-file in getMethodName()")
-		} else if err != nil {
+		if err != nil && err != io.EOF {
 			return "", err
+		} else if tok == nil {
+			return "", errors.New("Unexpected end-of-file in getMethodName()")
 		}
 
 		if tok.IsText() {
@@ -104,11 +109,11 @@ func getMethodData(p *xml.Decoder) ([]interface{}, *Fault, error) {
 
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		} else if tok == nil {
 			return nil, nil, errors.New("Unexpected end-of-file in" +
 				" getMethodData()")
-		} else if err != nil {
-			return nil, nil, err
 		}
 
 		if tok.Is(tokenParams) {
@@ -124,13 +129,14 @@ func getMethodData(p *xml.Decoder) ([]interface{}, *Fault, error) {
 				inParam = tok.IsStart()
 				continue
 			} else if inParam {
-				p, perr := getValue(p)
+				p, perr := getValueAfter(p, tok)
 				if perr != nil {
 					return nil, nil, perr
 				}
 
 				params = append(params, p)
 				inParam = false
+				continue
 			}
 		}
 
@@ -144,12 +150,13 @@ func getMethodData(p *xml.Decoder) ([]interface{}, *Fault, error) {
 			continue
 		} else if inFault {
 			var ferr error
-			fault, ferr = getFault(p)
+			fault, ferr = getFault(p, tok)
 			if ferr != nil {
 				return nil, nil, ferr
 			}
 
 			inFault = false
+			continue
 		}
 
 		if !tok.IsText() {
@@ -162,8 +169,8 @@ func getMethodData(p *xml.Decoder) ([]interface{}, *Fault, error) {
 }
 
 // get the XML-RPC fault
-func getFault(p *xml.Decoder) (*Fault, error) {
-	val, err := getValue(p)
+func getFault(p *xml.Decoder, tok *xmlToken) (*Fault, error) {
+	val, err := getValueAfter(p, tok)
 	if err != nil {
 		return nil, err
 	}
@@ -176,38 +183,59 @@ func getFault(p *xml.Decoder) (*Fault, error) {
 
 // parse a <value>
 func getValue(p *xml.Decoder) (interface{}, error) {
-	var value interface{}
-
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
+		if err != nil && err != io.EOF {
+			return nil, err
+		} else if tok == nil {
 			return nil, errors.New("Unexpected end-of-file in getValue()")
-		} else if err != nil {
+		}
+
+		if tok.Is(tokenValue) && tok.IsStart() {
+			return getValueContents(p)
+		} else if !tok.IsText() {
+			err = fmt.Errorf("Unexpected value token %v", tok)
 			return nil, err
 		}
+	}
+}
 
-		if tok.Is(tokenValue) {
-			if !tok.IsStart() {
-				// found end marker for tag, so we're done
-				break
-			}
+// parse a <value> when 'tok' has already been read; compact XML with no
+// whitespace between tags means 'tok' may be the <value> start tag itself
+func getValueAfter(p *xml.Decoder, tok *xmlToken) (interface{}, error) {
+	if tok.Is(tokenValue) && tok.IsStart() {
+		return getValueContents(p)
+	}
 
-			var sawEndValue bool
-			value, sawEndValue, err = getValueData(p)
-			if err != nil {
-				return nil, err
-			} else if sawEndValue {
-				if value == nil {
-					value = ""
-				}
+	return getValue(p)
+}
 
-				break
-			}
+// parse everything after a <value> start tag up to and including the
+// matching end tag
+func getValueContents(p *xml.Decoder) (interface{}, error) {
+	value, sawEndValue, err := getValueData(p)
+	if err != nil {
+		return nil, err
+	} else if sawEndValue {
+		if value == nil {
+			value = ""
+		}
 
-			continue
+		return value, nil
+	}
+
+	for {
+		tok, err := getNextToken(p)
+		if err != nil && err != io.EOF {
+			return nil, err
+		} else if tok == nil {
+			return nil, errors.New("Unexpected end-of-file in getValue()")
 		}
 
-		if !tok.IsText() {
+		if tok.Is(tokenValue) && !tok.IsStart() {
+			// found end marker for tag, so we're done
+			break
+		} else if !tok.IsText() {
 			err = fmt.Errorf("Unexpected value token %v", tok)
 			return nil, err
 		}
@@ -222,11 +250,11 @@ func getValueData(p *xml.Decoder) (interface{}, bool, error) {
 	var value interface{}
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
+		if err != nil && err != io.EOF {
+			return nil, false, err
+		} else if tok == nil {
 			return nil, false, errors.New("Unexpected end-of-file" +
 				" in getValue()")
-		} else if err != nil {
-			return nil, false, err
 		}
 
 		if tok.IsDataType() {
@@ -279,10 +307,10 @@ func getStruct(p *xml.Decoder) (map[string]interface{}, error) {
 
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
-			return nil, errors.New("Unexpected end-of-file in getStruct()")
-		} else if err != nil {
+		if err != nil && err != io.EOF {
 			return nil, err
+		} else if tok == nil {
+			return nil, errors.New("Unexpected end-of-file in getStruct()")
 		}
 
 		if tok.Is(tokenStruct) {
@@ -341,10 +369,10 @@ func getArray(p *xml.Decoder) (interface{}, error) {
 
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
-			return nil, errors.New("Unexpected end-of-file in getArray()")
-		} else if err != nil {
+		if err != nil && err != io.EOF {
 			return nil, err
+		} else if tok == nil {
+			return nil, errors.New("Unexpected end-of-file in getArray()")
 		}
 
 		if tok.Is(tokenArray) {
@@ -408,10 +436,10 @@ fmt.Printf("#%d append %v<%T> to %v<%T>\n", i, v, v, array, array)
 // parse either a raw string or a <string>xxx</string>
 func getText(p *xml.Decoder) (string, error) {
 	tok, err := getNextToken(p)
-	if tok == nil {
-		return "", errors.New("Unexpected end-of-file in getText()")
-	} else if err != nil {
+	if err != nil && err != io.EOF {
 		return "", err
+	} else if tok == nil {
+		return "", errors.New("Unexpected end-of-file in getText()")
 	}
 
 	if tok.Is(tokenString) && !tok.IsStart() {
@@ -434,6 +462,35 @@ func getDateISO8601(p *xml.Decoder) (interface{}, error) {
 	return time.Parse(ISO8601_LAYOUT, valStr)
 }
 
+// decode a <base64> value, ignoring any embedded whitespace and
+// missing padding
+func getBase64(p *xml.Decoder) (interface{}, error) {
+	valStr, err := getText(p)
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeBase64(valStr)
+}
+
+// decode base64 text, ignoring any embedded whitespace and missing padding
+func decodeBase64(valStr string) ([]byte, error) {
+	valStr = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, valStr)
+
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimRight(valStr,
+		"="))
+	if err != nil {
+		return nil, fmt.Errorf("Bad <base64> value: %v", err)
+	}
+
+	return data, nil
+}
+
 // convert the XML-RPC to Go data
 func getData(p *xml.Decoder, tok *xmlToken) (interface{}, error) {
 	var valStr string
@@ -443,7 +500,7 @@ func getData(p *xml.Decoder, tok *xmlToken) (interface{}, error) {
 	case tokenArray:
 		return getArray(p)
 	case tokenBase64:
-		return nil, errors.New("parseDataString(base64) unimplemented")
+		return getBase64(p)
 	case tokenBoolean:
 		valStr, err = getText(p)
 		if err != nil {
@@ -482,6 +539,18 @@ func getData(p *xml.Decoder, tok *xmlToken) (interface{}, error) {
 			return nil, err
 		}
 
+		return i, nil
+	case tokenI8:
+		valStr, err = getText(p)
+		if err != nil {
+			return nil, err
+		}
+
+		i, err := strconv.ParseInt(strings.TrimSpace(valStr), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+
 		return i, nil
 	case tokenNil:
 		return nil, nil
@@ -503,7 +572,28 @@ func getData(p *xml.Decoder, tok *xmlToken) (interface{}, error) {
 
 // Translate an XML stream into a local data object
 func Unmarshal(r io.Reader) (string, interface{}, error, *Fault) {
-	p := xml.NewDecoder(r)
+	methodName, params, err, fault := unmarshalParams(r)
+	if err != nil {
+		return "", nil, err, nil
+	}
+
+	return methodName, extractParams(params), nil, fault
+}
+
+// translate an XML stream into the method name and the list of parameters
+func unmarshalParams(r io.Reader) (string, []interface{}, error, *Fault) {
+	return unmarshalCharset(r, "")
+}
+
+// translate an XML stream in the named character set (or the one given by
+// its XML declaration if 'charset' is empty) into the method name and the
+// list of parameters
+func unmarshalCharset(r io.Reader, charset string) (string, []interface{},
+	error, *Fault) {
+	p, err := newXMLDecoder(r, charset)
+	if err != nil {
+		return "", nil, err, nil
+	}
 
 	var methodName string
 	var params []interface{}
@@ -512,10 +602,10 @@ func Unmarshal(r io.Reader) (string, interface{}, error, *Fault) {
 	isResp := false
 	for {
 		tok, err := getNextToken(p)
-		if tok == nil {
-			break
-		} else if err != nil {
+		if err != nil && err != io.EOF {
 			return "", nil, err, nil
+		} else if tok == nil {
+			break
 		}
 
 		if tok.IsNone() || tok.IsText() {
@@ -552,7 +642,7 @@ func Unmarshal(r io.Reader) (string, interface{}, error, *Fault) {
 		}
 	}
 
-	return methodName, extractParams(params), nil, fault
+	return methodName, params, nil, fault
 }
 
 // Translate an XML string into a local data object
@@ -560,215 +650,160 @@ func UnmarshalString(s string) (string, interface{}, error, *Fault) {
 	return Unmarshal(strings.NewReader(s))
 }
 
-// translate an array into XML
-func wrapArray(w io.Writer, val reflect.Value) error {
-	fmt.Fprintf(w, "<array><data>\n")
+// translate an array or slice into an XML-RPC <array>
+func makeArray(val reflect.Value) (*Value, error) {
+	v := &Value{Kind: KindArray, Array: make([]*Value, 0, val.Len())}
 
 	for i := 0; i < val.Len(); i++ {
-		fmt.Fprintf(w, "<value>")
-		aerr := wrapValue(w, val.Index(i))
-		if aerr != nil {
-			return aerr
+		elem, err := makeValue(val.Index(i))
+		if err != nil {
+			return nil, err
 		}
-		fmt.Fprintf(w, "</value>\n")
+		v.Array = append(v.Array, elem)
 	}
 
-	fmt.Fprintf(w, "</data></array>")
-	return nil
+	return v, nil
 }
 
-// translate a parameter into XML
-func wrapParam(w io.Writer, i int, xval interface{}) error {
-	var valStr string
+// translate a map with string keys into an XML-RPC <struct>, with the
+// members sorted by name
+func makeStruct(val reflect.Value) (*Value, error) {
+	keys := make([]string, 0, val.Len())
+	for _, k := range val.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
 
-	fmt.Fprintf(w, "	<param>\n	  <value>\n		")
-	if xval == nil {
-		valStr = "<nil/>"
-	} else {
-		err := wrapValue(w, reflect.ValueOf(xval))
+	v := &Value{Kind: KindStruct, Members: make([]*Member, 0, len(keys))}
+
+	for _, k := range keys {
+		key := reflect.ValueOf(k).Convert(val.Type().Key())
+		mval, err := makeValue(val.MapIndex(key))
 		if err != nil {
-			return err
+			return nil, err
 		}
+		v.Members = append(v.Members, &Member{Name: k, Value: mval})
 	}
-	fmt.Fprintf(w, "%s\n	  </value>\n	</param>\n", valStr)
 
-	return nil
+	return v, nil
 }
 
-// cached time.Time reflect.Type value
-var timeType reflect.Type
+// translate the exported fields of a Go struct into an XML-RPC <struct>
+func makeGoStruct(val reflect.Value) (*Value, error) {
+	v := &Value{Kind: KindStruct, Members: []*Member{}}
+
+	st := val.Type()
+	for i := 0; i < st.NumField(); i++ {
+		name, omitEmpty, ok := fieldName(st.Field(i))
+		if !ok || (omitEmpty && val.Field(i).IsZero()) {
+			continue
+		}
+
+		mval, err := makeValue(val.Field(i))
+		if err != nil {
+			return nil, err
+		}
+		v.Members = append(v.Members, &Member{Name: name, Value: mval})
+	}
+
+	return v, nil
+}
 
-// translate Go data into XML
-func wrapValue(w io.Writer, val reflect.Value) error {
-	var isError = false
+// cached time.Time reflect.Type value
+var timeType = reflect.TypeOf(time.Time{})
 
+// translate Go data into an XML-RPC value
+func makeValue(val reflect.Value) (*Value, error) {
 	switch val.Kind() {
+	case reflect.Invalid:
+		return &Value{Kind: KindNil}, nil
 	case reflect.Bool:
-		var bval int
 		if val.Bool() {
-			bval = 1
-		} else {
-			bval = 0
-		}
-		fmt.Fprintf(w, "<boolean>%d</boolean>", bval)
-	case reflect.Float32:
-		fmt.Fprintf(w, "<double>%f</double>", val.Float())
-	case reflect.Float64:
-		fmt.Fprintf(w, "<double>%f</double>", val.Float())
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		fmt.Fprintf(w, "<int>%d</int>", val.Int())
+			return &Value{Kind: KindBoolean, Text: "1"}, nil
+		}
+		return &Value{Kind: KindBoolean, Text: "0"}, nil
+	case reflect.Float32, reflect.Float64:
+		// the shortest text which parses back to the same number
+		return &Value{Kind: KindDouble, Text: strconv.FormatFloat(
+			val.Float(), 'f', -1, val.Type().Bits())}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return &Value{Kind: KindInt,
+			Text: strconv.FormatInt(val.Int(), 10)}, nil
 	case reflect.String:
-		fmt.Fprintf(w, "<string>%s</string>", val.String())
-	case reflect.Uint:
-		isError = true
-	case reflect.Uint8:
-		isError = true
-	case reflect.Uint16:
-		isError = true
-	case reflect.Uint32:
-		isError = true
-	case reflect.Uint64:
-		isError = true
-	case reflect.Uintptr:
-		isError = true
-	case reflect.Complex64:
-		isError = true
-	case reflect.Complex128:
-		isError = true
+		return &Value{Kind: KindString, Text: val.String()}, nil
 	case reflect.Array:
-		aerr := wrapArray(w, val)
-		if aerr != nil {
-			return aerr
-		}
-	case reflect.Chan:
-		isError = true
-	case reflect.Func:
-		isError = true
-	case reflect.Interface:
-		isError = true
+		return makeArray(val)
+	case reflect.Interface, reflect.Ptr:
+		if val.IsNil() {
+			return &Value{Kind: KindNil}, nil
+		}
+
+		return makeValue(val.Elem())
 	case reflect.Map:
-		isError = true
-	case reflect.Ptr:
-		isError = true
-	case reflect.Slice:
-		aerr := wrapArray(w, val)
-		if aerr != nil {
-			return aerr
+		if val.Type().Key().Kind() == reflect.String {
+			return makeStruct(val)
 		}
-	case reflect.Struct:
-		if timeType == nil {
-			timeType = reflect.TypeOf((*time.Time)(nil)).Elem()
+	case reflect.Slice:
+		if val.Type().Elem().Kind() == reflect.Uint8 {
+			return &Value{Kind: KindBase64, Bytes: val.Bytes()}, nil
 		}
 
+		return makeArray(val)
+	case reflect.Struct:
 		if !val.Type().ConvertibleTo(timeType) {
-			isError = true
-		} else {
-			method := val.MethodByName("Format")
-			params := []reflect.Value{reflect.ValueOf(ISO8601_LAYOUT)}
-			rtn := method.Call(params)
-
-			tag := "dateTime.iso8601"
-			fmt.Fprintf(w, "<%s>%s</%s>", tag, rtn[0].String(), tag)
+			return makeGoStruct(val)
 		}
-	case reflect.UnsafePointer:
-		isError = true
-	default:
-		return fmt.Errorf("Unknown Kind %v for %T (%v)", val.Kind(), val, val)
-	}
 
-	if isError {
-		return fmt.Errorf("Not wrapping type %v (%v)", val.Kind().String(), val)
+		tval := val.Convert(timeType).Interface().(time.Time)
+		return &Value{Kind: KindDateTime,
+			Text: tval.Format(ISO8601_LAYOUT)}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr, reflect.Complex64,
+		reflect.Complex128, reflect.Chan, reflect.Func,
+		reflect.UnsafePointer:
+		// no XML-RPC equivalent
+	default:
+		return nil, fmt.Errorf("Unknown Kind %v for %T (%v)", val.Kind(),
+			val, val)
 	}
 
-	return nil
-}
-
-// Write a local data object as an XML-RPC request
-func Marshal(w io.Writer, methodName string, args ...interface{}) error {
-	return marshalArray(w, methodName, args)
+	return nil, fmt.Errorf("Not wrapping type %v (%v)", val.Kind().String(),
+		val)
 }
 
-// Write an array of zero or more data objects as an XML-RPC request
-func marshalArray(w io.Writer, methodName string, args []interface{}) error {
-	var name string
-	var addExtra bool
-	if methodName == "" {
-		name = "Response"
-		addExtra = false
-	} else {
-		name = "Call"
-		addExtra = true
-	}
-
-	fmt.Fprintf(w, "<?xml version=\"1.0\"?>\n<method%s>\n", name)
-	if addExtra {
-		fmt.Fprintf(w, "  <methodName>%s</methodName>\n", methodName)
-	}
+// build a <methodCall> for 'methodName' (or a <methodResponse> if the
+// name is empty) holding the arguments
+func makeDocument(methodName string, args []interface{}) (*Document,
+	error) {
+	doc := &Document{Response: methodName == "", MethodName: methodName,
+		Params: make([]*Value, 0, len(args))}
 
-	fmt.Fprintf(w, "  <params>\n")
-
-	for i, a := range args {
-		err := wrapParam(w, i, a)
+	for _, a := range args {
+		v, err := makeValue(reflect.ValueOf(a))
 		if err != nil {
-			return err
+			return nil, err
 		}
+		doc.Params = append(doc.Params, v)
 	}
 
-	fmt.Fprintf(w, "  </params>\n</method%s>\n", name)
-
-	return nil
-}
-
-// XML-RPC client data
-type Client struct {
-	http.Client
-	urlStr string
+	return doc, nil
 }
 
-// connect to a remote XML-RPC server
-func NewClient(host string, port int) (*Client, error) {
-	address := fmt.Sprintf("http://%s:%d/RPC2", host, port)
-
-	uurl, uerr := url.Parse(address)
-	if uerr != nil {
-		return nil, uerr
-	}
-
-	return &Client{urlStr: uurl.String()}, nil
+// Write a local data object as an XML-RPC request
+func Marshal(w io.Writer, methodName string, args ...interface{}) error {
+	return marshalArray(w, methodName, args)
 }
 
-// call a procedure on a remote XML-RPC server
-func (c *Client) RPCCall(methodName string,
-	args ...interface{}) (interface{}, error, *Fault) {
-
-	buf := bytes.NewBufferString("")
-	berr := marshalArray(buf, methodName, args)
-	if berr != nil {
-		return nil, berr, nil
-	}
-
-	req, err := http.NewRequest("POST", c.urlStr,
-		strings.NewReader(buf.String()))
-	if err != nil {
-		return nil, err, nil
-	}
-
-	req.Header.Add("Content-Type", "text/xml")
-
-	r, err := c.Do(req)
+// Write an array of zero or more data objects as an XML-RPC request,
+// indented by two spaces for each level
+func marshalArray(w io.Writer, methodName string, args []interface{}) error {
+	doc, err := makeDocument(methodName, args)
 	if err != nil {
-		return nil, err, nil
-	} else if r == nil {
-		err = fmt.Errorf("PostString for %s returned nil response\n",
-			methodName)
-		return nil, err, nil
-	}
-
-	_, pval, perr, pfault := Unmarshal(r.Body)
-
-	if r.Close {
-		r.Body.Close()
+		return err
 	}
 
-	return pval, perr, pfault
+	e := NewEncoder(w)
+	e.SetIndent("  ")
+	return e.Encode(doc)
 }
@@ -4,85 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"reflect"
-	"strings"
 	"testing"
 	"time"
 )
 
-func getTypeString(val interface{}, noSpaces bool) string {
-	preSpace := "\n		"
-	postSpace := "\n	  "
-
-	var pre, post string
-	if noSpaces {
-		pre = ""
-		post = ""
-	} else {
-		pre = preSpace
-		post = postSpace
-	}
-
-	if val == nil {
-		return pre + "<nil/>" + post
-	}
-
-	switch v := val.(type) {
-	case bool:
-		var bVal int
-		if v {
-			bVal = 1
-		} else {
-			bVal = 0
-		}
-		return fmt.Sprintf("%s<boolean>%d</boolean>%s", pre, bVal, post)
-	case float64:
-		// hack to make float values match
-		fStr := fmt.Sprintf("%f", v)
-		fLen := len(fStr)
-		fSub := fStr[fLen-3 : fLen]
-		if fLen > 3 && fSub != "000" {
-			fStr += "000"
-		}
-
-		return fmt.Sprintf("%s<double>%s</double>%s", pre, fStr, post)
-	case int:
-		return fmt.Sprintf("%s<int>%d</int>%s", pre, v, post)
-	case string:
-		return v
-	case (map[string]interface{}):
-		valStr := fmt.Sprintf("%s<struct>", preSpace)
-		for mkey, mval := range v {
-			valStr += fmt.Sprintf(`
-		  <member>
-			<name>%s</name>
-			<value>%v</value>
-		  </member>`, mkey, getTypeString(mval, true))
-		}
-		valStr += fmt.Sprintf("%s</struct>%s", preSpace, postSpace)
-		return valStr
-	case time.Time:
-		tag := "dateTime.iso8601"
-		return fmt.Sprintf("%s<%s>%s</%s>%s", pre, tag,
-			v.Format(ISO8601_LAYOUT), tag, post)
-	}
-
-	rval := reflect.ValueOf(val)
-	if rval.Kind() == reflect.Array || rval.Kind() == reflect.Slice {
-		buf := bytes.NewBufferString("\n		<array><data>\n")
-		for i := 0; i < rval.Len(); i++ {
-			buf.WriteString("<value>")
-			wrapValue(buf, rval.Index(i))
-			buf.WriteString("</value>\n")
-		}
-		buf.WriteString("</data></array>\n	  ")
-		return buf.String()
-	} else {
-		fmt.Printf("Not handling Kind %v\n", rval.Kind())
-	}
-
-	return fmt.Sprintf("<???>%v(%T)</???>", val, val)
-}
-
 // Translate a local data object into an XML string
 func marshalString(methodName string, args ...interface{}) (string, error) {
 	buf := bytes.NewBufferString("")
@@ -105,9 +30,9 @@ func parseAndCheck(t *testing.T, methodName string, expVal interface{},
 
 	if name != methodName {
 		if methodName == "" {
-			t.Fatal("Did not expect method name \"%s\"", name)
+			t.Fatalf("Did not expect method name \"%s\"", name)
 		} else {
-			t.Fatal("Expected method name \"%s\", not \"%s\"", methodName, name)
+			t.Fatalf("Expected method name \"%s\", not \"%s\"", methodName, name)
 		}
 	}
 
@@ -126,140 +51,114 @@ func parseAndCheck(t *testing.T, methodName string, expVal interface{},
 	}
 }
 
-func parseUnimplemented(t *testing.T, methodName string, expVal interface{}) {
-	xmlStr := wrapMethod(methodName, expVal)
-	name, val, err, fault := UnmarshalString(xmlStr)
-	if err == nil {
-		t.Fatalf("Unimplemented type didn't return an error")
-	} else if !strings.Contains(err.Error(), "nimplemented") {
-		t.Fatalf("Returned unexpected error %s", err)
-	}
-
-	if fault != nil {
-		t.Fatalf("Returned unexpected fault %s", fault)
-	}
-
-	if name != methodName {
-		if methodName == "" {
-			t.Fatal("Did not expect method name \"%s\"", name)
-		} else {
-			t.Fatal("Expected method name \"%s\", not \"%s\"", methodName, name)
-		}
-	}
-
-	if val != nil {
-		t.Fatalf("Got value %v from unimplemented type", val)
-	}
-}
-
-func wrapAndParse(t *testing.T, methodName string, expVal interface{}) {
-	xmlStr := wrapMethod(methodName, expVal)
-	parseAndCheck(t, methodName, expVal, xmlStr)
-}
-
-func wrapMethod(methodName string, args ...interface{}) string {
-	buf := bytes.NewBufferString("<?xml version=\"1.0\"?>\n")
-
-	var backStr string
-	if methodName == "" {
-		fmt.Fprintf(buf, "<methodResponse>\n")
-		backStr = "</methodResponse>"
-	} else {
-		fmt.Fprintf(buf, "<methodCall>\n  <methodName>%s</methodName>\n",
-			methodName)
-		backStr = "</methodCall>"
-	}
-
-	fmt.Fprintf(buf, "  <params>\n")
-	for _, a := range args {
-		fmt.Fprintf(buf, `	<param>
-	  <value>%v</value>
-	</param>
-`, getTypeString(a, false))
-	}
-	fmt.Fprintf(buf, "  </params>\n%s\n", backStr)
-
-	return string(buf.Bytes())
-}
-
-func TestMakeRequestBool(t *testing.T) {
-	expVal := true
-	methodName := "foo"
-
-	xmlStr, err := marshalString(methodName, expVal)
+// marshal a request and compare it with the expected XML
+func marshalAndCheck(t *testing.T, expStr string, methodName string,
+	args ...interface{}) {
+	xmlStr, err := marshalString(methodName, args...)
 	if err != nil {
 		t.Fatalf("Returned error %s", err)
-	}
-
-	expStr := wrapMethod(methodName, expVal)
-	if xmlStr != expStr {
+	} else if xmlStr != expStr {
 		t.Fatalf("Returned \"%s\", not \"%s\"", xmlStr, expStr)
 	}
 }
 
-func TestMakeRequestDateTime(t *testing.T) {
-	val := "19980717T14:08:55"
-	expVal, err := time.Parse(ISO8601_LAYOUT, val)
-	if err != nil {
-		t.Errorf("Cannot create ISO8601 time \"%s\"\n", val)
-	}
-
-	methodName := "foo"
+func TestMakeRequestBool(t *testing.T) {
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><boolean>1</boolean></value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", true)
+}
 
-	xmlStr, err := marshalString(methodName, expVal)
+func TestMakeRequestDateTime(t *testing.T) {
+	val, err := time.Parse(ISO8601_LAYOUT, "19980717T14:08:55")
 	if err != nil {
-		t.Fatalf("Returned error %s", err)
+		t.Fatal(err)
 	}
 
-	expStr := wrapMethod(methodName, expVal)
-	if xmlStr != expStr {
-		t.Fatalf("Returned \"%s\", not \"%s\"", xmlStr, expStr)
-	}
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><dateTime.iso8601>19980717T14:08:55</dateTime.iso8601></value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", val)
 }
 
 func TestMakeRequestInt(t *testing.T) {
-	expVal := 123456
-	methodName := "foo"
-
-	xmlStr, err := marshalString(methodName, expVal)
-	if err != nil {
-		t.Fatalf("Returned error %s", err)
-	}
-
-	expStr := wrapMethod(methodName, expVal)
-	if xmlStr != expStr {
-		t.Fatalf("Returned \"%s\", not \"%s\"", xmlStr, expStr)
-	}
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><int>123456</int></value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", 123456)
 }
 
 func TestMakeRequestArray(t *testing.T) {
-	expVal := []int{1, 2, 3, 4}
-	methodName := "foo"
-
-	xmlStr, err := marshalString(methodName, expVal)
-	if err != nil {
-		t.Fatalf("Returned error %s", err)
-	}
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value>
+        <array>
+          <data>
+            <value><int>1</int></value>
+            <value><int>2</int></value>
+            <value><int>3</int></value>
+            <value><int>4</int></value>
+          </data>
+        </array>
+      </value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", []int{1, 2, 3, 4})
+}
 
-	expStr := wrapMethod(methodName, expVal)
-	if xmlStr != expStr {
-		t.Fatalf("Returned \"%s\", not \"%s\"", xmlStr, expStr)
-	}
+func TestMakeRequestDouble(t *testing.T) {
+	// doubles are written without an exponent or any loss of precision
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><double>0.0000001</double></value>
+    </param>
+    <param>
+      <value><double>123.456789012345</double></value>
+    </param>
+    <param>
+      <value><double>0.1</double></value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", 1e-7, 123.456789012345, float32(0.1))
 }
 
 func TestMakeRequestNil(t *testing.T) {
-	var expVal interface{} = nil
-	methodName := "foo"
-
-	xmlStr, err := marshalString(methodName, expVal)
-	if err != nil {
-		t.Fatalf("Returned error %s", err)
-	}
-
-	expStr := wrapMethod(methodName, expVal)
-	if xmlStr != expStr {
-		t.Fatalf("Returned \"%s\", not \"%s\"", xmlStr, expStr)
-	}
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><nil/></value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", nil)
 }
 
 func TestMakeRequestNoData(t *testing.T) {
@@ -284,40 +183,106 @@ func TestMakeRequestNoData(t *testing.T) {
 }
 
 func TestParseRequestInt(t *testing.T) {
-	wrapAndParse(t, "foo", 54321)
+	xmlStr := `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><int>54321</int></value>
+    </param>
+  </params>
+</methodCall>`
+
+	parseAndCheck(t, "foo", 54321, xmlStr)
 }
 
-func XXXTestParseResponseArray(t *testing.T) {
-	var array = []int{1, -1, 0, 1234567}
-	wrapAndParse(t, "", array)
+func TestParseResponseArray(t *testing.T) {
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><array><data>
+        <value><int>1</int></value>
+        <value><i4>-1</i4></value>
+        <value><int>0</int></value>
+        <value><int>1234567</int></value>
+      </data></array></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	// arrays are always decoded as []interface{}
+	parseAndCheck(t, "", []interface{}{1, -1, 0, 1234567}, xmlStr)
 }
 
 func TestParseResponseBase64(t *testing.T) {
-	tnm := "base64"
-	val := "eW91IGNhbid0IHJlYWQgdGhpcyE"
-	parseUnimplemented(t, "", fmt.Sprintf("<%s>%v</%s>", tnm, val, tnm))
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><base64>eW91IGNhbid0IHJlYWQgdGhpcyE</base64></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", []byte("you can't read this!"), xmlStr)
 }
 
-func TestParseResponseBool(t *testing.T) {
-	const expVal = true
+func TestMakeRequestBase64(t *testing.T) {
+	marshalAndCheck(t, `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value><base64>eW91IGNhbid0IHJlYWQgdGhpcyE=</base64></value>
+    </param>
+  </params>
+</methodCall>
+`, "foo", []byte("you can't read this!"))
+}
 
-	xmlStr := wrapMethod("", expVal)
+func TestParseResponseBool(t *testing.T) {
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><boolean>1</boolean></value>
+    </param>
+  </params>
+</methodResponse>`
 
-	parseAndCheck(t, "", expVal, xmlStr)
+	parseAndCheck(t, "", true, xmlStr)
 }
 
 func TestParseResponseDatetime(t *testing.T) {
-	val := "19980717T14:08:55"
-	expVal, err := time.Parse(ISO8601_LAYOUT, val)
+	expVal, err := time.Parse(ISO8601_LAYOUT, "19980717T14:08:55")
 	if err != nil {
-		t.Errorf("Cannot create ISO8601 time \"%s\"\n", val)
+		t.Fatal(err)
 	}
 
-	wrapAndParse(t, "", expVal)
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><dateTime.iso8601>19980717T14:08:55</dateTime.iso8601></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", expVal, xmlStr)
 }
 
 func TestParseResponseDouble(t *testing.T) {
-	wrapAndParse(t, "", 123.456)
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><double>123.456000</double></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", 123.456, xmlStr)
 }
 
 func TestParseResponseFault(t *testing.T) {
@@ -357,20 +322,62 @@ func TestParseResponseFault(t *testing.T) {
 	}
 }
 
+func TestParseCompact(t *testing.T) {
+	xmlStr := `<?xml version="1.0"?><methodCall><methodName>foo</methodName>` +
+		`<params><param><value><i4>3</i4></value></param><param><value>` +
+		`abc</value></param></params></methodCall>`
+	parseAndCheck(t, "foo", []interface{}{3, "abc"}, xmlStr)
+
+	xmlStr = `<?xml version="1.0"?><methodResponse><fault><value><struct>` +
+		`<member><name>faultCode</name><value><i4>4</i4></value></member>` +
+		`<member><name>faultString</name><value>Bad</value></member>` +
+		`</struct></value></fault></methodResponse>`
+
+	_, _, err, fault := UnmarshalString(xmlStr)
+	if err != nil {
+		t.Fatalf("Returned error %s", err)
+	} else if fault == nil || fault.Code != 4 || fault.Msg != "Bad" {
+		t.Fatalf("Returned fault %v", fault)
+	}
+}
+
 func TestParseResponseInt(t *testing.T) {
-	wrapAndParse(t, "", 1279905716)
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><int>1279905716</int></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", 1279905716, xmlStr)
 }
 
 func TestParseResponseI4(t *testing.T) {
-	tnm := "i4"
-	val := -433221
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><i4>-433221</i4></value>
+    </param>
+  </params>
+</methodResponse>`
 
-	xmlStr := wrapMethod("", fmt.Sprintf("<%s>%v</%s>", tnm, val, tnm))
-	parseAndCheck(t, "", val, xmlStr)
+	parseAndCheck(t, "", -433221, xmlStr)
 }
 
 func TestParseResponseNil(t *testing.T) {
-	wrapAndParse(t, "", nil)
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><nil/></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", nil, xmlStr)
 }
 
 func TestParseResponseNoData(t *testing.T) {
@@ -384,11 +391,29 @@ func TestParseResponseNoData(t *testing.T) {
 }
 
 func TestParseResponseString(t *testing.T) {
-	wrapAndParse(t, "", "abc123")
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><string>abc123</string></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", "abc123", xmlStr)
 }
 
 func TestParseResponseStringEmpty(t *testing.T) {
-	wrapAndParse(t, "", "")
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><string></string></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	parseAndCheck(t, "", "", xmlStr)
 }
 
 func TestParseResponseStringRaw(t *testing.T) {
@@ -433,8 +458,97 @@ func TestParseResponseStringEscapedChars(t *testing.T) {
 }
 
 func TestParseResponseStruct(t *testing.T) {
-	structMap := map[string]interface{}{
+	xmlStr := `<?xml version="1.0"?>
+<methodResponse>
+  <params>
+    <param>
+      <value><struct>
+        <member><name>boolVal</name><value><boolean>1</boolean></value></member>
+        <member><name>intVal</name><value><int>18</int></value></member>
+        <member><name>strVal</name><value><string>foo</string></value></member>
+      </struct></value>
+    </param>
+  </params>
+</methodResponse>`
+
+	expVal := map[string]interface{}{
 		"boolVal": true, "intVal": 18, "strVal": "foo",
 	}
-	wrapAndParse(t, "", structMap)
+	parseAndCheck(t, "", expVal, xmlStr)
+}
+
+func TestMakeRequestStruct(t *testing.T) {
+	structMap := map[string]interface{}{
+		"strVal": "foo", "boolVal": true, "intVal": 18,
+		"listVal": []interface{}{1, "two"},
+	}
+
+	xmlStr, err := marshalString("foo", structMap)
+	if err != nil {
+		t.Fatalf("Returned error %s", err)
+	}
+
+	// members are sorted and every level is indented by two spaces
+	expStr := `<?xml version="1.0"?>
+<methodCall>
+  <methodName>foo</methodName>
+  <params>
+    <param>
+      <value>
+        <struct>
+          <member>
+            <name>boolVal</name>
+            <value><boolean>1</boolean></value>
+          </member>
+          <member>
+            <name>intVal</name>
+            <value><int>18</int></value>
+          </member>
+          <member>
+            <name>listVal</name>
+            <value>
+              <array>
+                <data>
+                  <value><int>1</int></value>
+                  <value><string>two</string></value>
+                </data>
+              </array>
+            </value>
+          </member>
+          <member>
+            <name>strVal</name>
+            <value><string>foo</string></value>
+          </member>
+        </struct>
+      </value>
+    </param>
+  </params>
+</methodCall>
+`
+	if xmlStr != expStr {
+		t.Fatalf("Returned \"%s\", not \"%s\"", xmlStr, expStr)
+	}
+
+	parseAndCheck(t, "foo", structMap, xmlStr)
+}
+
+type marshalStruct struct {
+	Name    string
+	Count   int      `xmlrpc:"count"`
+	Skip    string   `xmlrpc:"-"`
+	Empty   string   `xmlrpc:"empty,omitempty"`
+	Ptr     *float64 `xmlrpc:"ptr"`
+	private int
+}
+
+func TestMakeRequestGoStruct(t *testing.T) {
+	val := &marshalStruct{Name: "abc", Count: 3, Skip: "x", private: 1}
+
+	xmlStr, err := marshalString("foo", val)
+	if err != nil {
+		t.Fatalf("Returned error %s", err)
+	}
+
+	expVal := map[string]interface{}{"Name": "abc", "count": 3, "ptr": nil}
+	parseAndCheck(t, "foo", expVal, xmlStr)
 }
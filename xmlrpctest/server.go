@@ -0,0 +1,286 @@
+/*
+Package xmlrpctest provides an in-memory XML-RPC server for testing code
+which uses an xmlrpc.Client.
+
+Tests declare the calls they expect along with the results to return,
+and Verify reports any expected calls which weren't made and any calls
+which weren't expected:
+
+	srv := xmlrpctest.NewServer()
+	defer srv.Close()
+
+	srv.Expect("blog.getPost").WithArgs(1234).Return(map[string]string{
+		"title": "Hello",
+	})
+	srv.Expect("blog.deletePost").WithArgs(99).Fault(404, "No such post")
+
+	client := srv.Client()
+	... code under test ...
+
+	srv.Verify(t)
+
+By default expectations may be met in any order; InOrder requires them to
+be met in the order they were declared.
+*/
+package xmlrpctest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/dancebear/go-xmlrpc/xmlrpc"
+)
+
+// fault code returned for calls which weren't expected
+const faultUnexpected = -32601
+
+// TestingT is the part of testing.T used by Verify
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// An Expectation describes a call the test expects and how to answer it
+type Expectation struct {
+	methodName string
+
+	// expected arguments, or nil to accept any arguments
+	args    []interface{}
+	hasArgs bool
+
+	// response
+	results []interface{}
+	fault   *xmlrpc.Fault
+
+	// number of calls expected (unlimited if anyTimes is set) and made
+	times    int
+	anyTimes bool
+	calls    int
+}
+
+// only match calls with these arguments
+//
+// Arguments are compared after conversion to XML-RPC, so []string{"a"}
+// matches an array holding the string "a" and a Go struct matches an
+// XML-RPC struct with the same members.
+func (e *Expectation) WithArgs(args ...interface{}) *Expectation {
+	e.args = args
+	e.hasArgs = true
+	return e
+}
+
+// answer matching calls with these values
+func (e *Expectation) Return(vals ...interface{}) *Expectation {
+	e.results = vals
+	e.fault = nil
+	return e
+}
+
+// answer matching calls with a fault
+func (e *Expectation) Fault(code int, msg string) *Expectation {
+	e.results = nil
+	e.fault = xmlrpc.NewFault(code, msg)
+	return e
+}
+
+// expect the call to be made 'n' times (the default is once)
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	e.anyTimes = false
+	return e
+}
+
+// accept any number of matching calls, including none
+func (e *Expectation) AnyTimes() *Expectation {
+	e.anyTimes = true
+	return e
+}
+
+// return true if the expectation can't match any more calls
+func (e *Expectation) exhausted() bool {
+	return !e.anyTimes && e.calls >= e.times
+}
+
+// return true if the expected number of calls has been made
+func (e *Expectation) satisfied() bool {
+	return e.anyTimes || e.calls >= e.times
+}
+
+// describe the expected call
+func (e *Expectation) String() string {
+	if !e.hasArgs {
+		return e.methodName + "(...)"
+	}
+
+	return describeCall(e.methodName, e.args)
+}
+
+// describe a call for error messages
+func describeCall(methodName string, args []interface{}) string {
+	strs := make([]string, len(args))
+	for i, arg := range args {
+		strs[i] = fmt.Sprintf("%#v", arg)
+	}
+
+	return fmt.Sprintf("%s(%s)", methodName, strings.Join(strs, ", "))
+}
+
+// convert values to the form Unmarshal would return for them
+func normalize(vals []interface{}) (interface{}, error) {
+	var buf bytes.Buffer
+	if err := xmlrpc.Marshal(&buf, "m", vals...); err != nil {
+		return nil, err
+	}
+
+	_, norm, err, _ := xmlrpc.Unmarshal(&buf)
+	return norm, err
+}
+
+// return true if the call matches the expectation
+func (e *Expectation) matches(methodName string, params []interface{}) bool {
+	if methodName != e.methodName {
+		return false
+	} else if !e.hasArgs {
+		return true
+	} else if len(params) != len(e.args) {
+		return false
+	}
+
+	expNorm, err := normalize(e.args)
+	if err != nil {
+		return false
+	}
+
+	norm, err := normalize(params)
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(expNorm, norm)
+}
+
+// A Server is an XML-RPC server, running on a local port, which answers
+// calls using a list of expectations
+type Server struct {
+	// base URL of the server
+	URL string
+
+	// the handler which serves requests; more interceptors can be added
+	// with Handler.Use
+	Handler *xmlrpc.Handler
+
+	srv *httptest.Server
+
+	mutex      sync.Mutex
+	expects    []*Expectation
+	ordered    bool
+	unexpected []string
+}
+
+// start a new server with no expectations
+func NewServer() *Server {
+	s := &Server{Handler: xmlrpc.NewHandler()}
+	s.Handler.Use(s.intercept)
+
+	s.srv = httptest.NewServer(s.Handler)
+	s.URL = s.srv.URL
+
+	return s
+}
+
+// shut down the server
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// return a client connected to the server
+func (s *Server) Client(opts ...xmlrpc.ClientOption) *xmlrpc.Client {
+	c, err := xmlrpc.NewClientURL(s.URL, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("Cannot create client for %s: %v", s.URL, err))
+	}
+
+	return c
+}
+
+// require expectations to be met in the order they were declared
+func (s *Server) InOrder() *Server {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.ordered = true
+	return s
+}
+
+// expect a single call to 'methodName' which returns no values; use the
+// Expectation's methods to change this
+func (s *Server) Expect(methodName string) *Expectation {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e := &Expectation{methodName: methodName, times: 1}
+	s.expects = append(s.expects, e)
+	return e
+}
+
+// find the expectation which answers a call
+func (s *Server) match(methodName string, params []interface{}) *Expectation {
+	for _, e := range s.expects {
+		if e.exhausted() {
+			continue
+		} else if e.matches(methodName, params) {
+			return e
+		} else if s.ordered && !e.satisfied() {
+			break
+		}
+	}
+
+	return nil
+}
+
+// answer a call using the expectations
+func (s *Server) intercept(ctx context.Context, methodName string,
+	params []interface{}, next xmlrpc.Invoker) ([]interface{},
+	*xmlrpc.Fault) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	e := s.match(methodName, params)
+	if e == nil {
+		desc := describeCall(methodName, params)
+		s.unexpected = append(s.unexpected, desc)
+		return nil, xmlrpc.NewFault(faultUnexpected,
+			fmt.Sprintf("Unexpected call %s", desc))
+	}
+
+	e.calls++
+	if e.fault != nil {
+		return nil, e.fault
+	}
+
+	return e.results, nil
+}
+
+// report any unexpected calls and any expectations which weren't met
+func (s *Server) Verify(t TestingT) {
+	t.Helper()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, desc := range s.unexpected {
+		t.Errorf("Unexpected XML-RPC call %s", desc)
+	}
+
+	for _, e := range s.expects {
+		if !e.satisfied() {
+			t.Errorf("Expected %d call(s) to %v, got %d", e.times, e,
+				e.calls)
+		}
+	}
+}
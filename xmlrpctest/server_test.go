@@ -0,0 +1,130 @@
+package xmlrpctest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestingT which records errors instead of failing the test
+type fakeT struct {
+	errors []string
+}
+
+func (ft *fakeT) Helper() {}
+
+func (ft *fakeT) Errorf(format string, args ...interface{}) {
+	ft.errors = append(ft.errors, fmt.Sprintf(format, args...))
+}
+
+type post struct {
+	Title string `xmlrpc:"title"`
+	Tags  []string
+}
+
+func TestExpectations(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	srv.Expect("blog.getPost").WithArgs(1).Return(post{Title: "Hello",
+		Tags: []string{"a", "b"}})
+	srv.Expect("blog.newPost").WithArgs(post{Title: "New",
+		Tags: []string{"x"}}).Return(2)
+	srv.Expect("blog.deletePost").Fault(404, "No such post")
+	srv.Expect("system.ping").AnyTimes()
+	srv.Expect("blog.count").Return(3).Times(2)
+
+	client := srv.Client()
+
+	val, cerr, fault := client.RPCCall("blog.getPost", 1)
+	if cerr != nil || fault != nil {
+		t.Fatalf("blog.getPost failed: %v, %v", cerr, fault)
+	}
+
+	m, ok := val.(map[string]interface{})
+	if !ok || m["title"] != "Hello" {
+		t.Fatalf("blog.getPost returned %v", val)
+	}
+
+	// arguments are matched by value, not by Go type
+	val, cerr, fault = client.RPCCall("blog.newPost",
+		map[string]interface{}{"title": "New",
+			"Tags": []interface{}{"x"}})
+	if cerr != nil || fault != nil || val != 2 {
+		t.Fatalf("blog.newPost returned %v, %v, %v", val, cerr, fault)
+	}
+
+	_, cerr, fault = client.RPCCall("blog.deletePost", 7)
+	if cerr != nil || fault == nil || fault.Code != 404 {
+		t.Fatalf("blog.deletePost returned %v, %v", cerr, fault)
+	}
+
+	for i := 0; i < 2; i++ {
+		if val, cerr, fault = client.RPCCall("blog.count"); cerr != nil ||
+			fault != nil || val != 3 {
+			t.Fatalf("blog.count returned %v, %v, %v", val, cerr, fault)
+		}
+	}
+
+	srv.Verify(t)
+}
+
+func TestVerifyFailures(t *testing.T) {
+	srv := NewServer()
+	defer srv.Close()
+
+	srv.Expect("blog.getPost").WithArgs(1).Return("x")
+	srv.Expect("blog.count").Times(2)
+
+	client := srv.Client()
+
+	// wrong arguments
+	_, cerr, fault := client.RPCCall("blog.getPost", 2)
+	if cerr != nil || fault == nil || fault.Code != faultUnexpected {
+		t.Fatalf("Unexpected call returned %v, %v", cerr, fault)
+	}
+
+	client.RPCCall("blog.count")
+
+	ft := &fakeT{}
+	srv.Verify(ft)
+
+	expErrors := []string{
+		"Unexpected XML-RPC call blog.getPost(2)",
+		"Expected 1 call(s) to blog.getPost(1), got 0",
+		"Expected 2 call(s) to blog.count(...), got 1",
+	}
+	if strings.Join(ft.errors, "\n") != strings.Join(expErrors, "\n") {
+		t.Fatalf("Verify reported:\n%s\nnot:\n%s",
+			strings.Join(ft.errors, "\n"), strings.Join(expErrors, "\n"))
+	}
+}
+
+func TestInOrder(t *testing.T) {
+	srv := NewServer().InOrder()
+	defer srv.Close()
+
+	srv.Expect("login").Return(true)
+	srv.Expect("work").AnyTimes()
+	srv.Expect("logout")
+
+	client := srv.Client()
+
+	if _, _, fault := client.RPCCall("work"); fault == nil {
+		t.Fatal("Out-of-order call was accepted")
+	}
+
+	for _, name := range []string{"login", "work", "work", "logout"} {
+		if _, cerr, fault := client.RPCCall(name); cerr != nil ||
+			fault != nil {
+			t.Fatalf("%s failed: %v, %v", name, cerr, fault)
+		}
+	}
+
+	ft := &fakeT{}
+	srv.Verify(ft)
+	if len(ft.errors) != 1 ||
+		ft.errors[0] != "Unexpected XML-RPC call work()" {
+		t.Fatalf("Verify reported %v", ft.errors)
+	}
+}